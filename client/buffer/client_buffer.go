@@ -2,7 +2,10 @@
 package buffer
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	syncutil "github.com/Andrew-M-C/go.util/sync"
@@ -17,6 +20,15 @@ type ClientBuffer[T any] struct {
 	mPrefix string
 	newer   func(string, ...client.Option) (T, error)
 	closer  func(T) error
+	options options[T]
+
+	updateLock sync.Mutex     // 保证同一时间只有一个 client 在重建
+	refresh    chan struct{}  // 后台刷新模式下的配置变化通知
+	done       chan struct{}  // Close 时关闭
+	routines   sync.WaitGroup // 后台刷新协程, Close 时等待其退出
+	isClosed   bool           // 受 updateLock 保护
+	retries    syncutil.Map[string, *retryState]
+	draining   syncutil.Map[*clientContext[T], string] // value 为 client name
 }
 
 // NewClientBuffer 新建一个 client 缓存池
@@ -24,88 +36,362 @@ func NewClientBuffer[T any](
 	metricsPrefix string,
 	newer func(string, ...client.Option) (T, error),
 	closer func(T) error,
+	opts ...Option[T],
 ) *ClientBuffer[T] {
 	b := &ClientBuffer[T]{
-		clients: syncutil.NewMap[string, *clientContext[T]](),
-		mPrefix: metricsPrefix,
-		newer:   newer,
-		closer:  closer,
+		clients:  syncutil.NewMap[string, *clientContext[T]](),
+		retries:  syncutil.NewMap[string, *retryState](),
+		draining: syncutil.NewMap[*clientContext[T], string](),
+		mPrefix:  metricsPrefix,
+		newer:    newer,
+		closer:   closer,
+		options:  mergeOptions(opts),
+		done:     make(chan struct{}),
+	}
+	if b.options.refreshInterval > 0 {
+		b.refresh = make(chan struct{}, 1)
+		internal.refreshNotifiers.Store(b.refresh, struct{}{})
+		b.routines.Add(1)
+		go b.refreshRoutine()
 	}
+	internal.buffers.Store(b, struct{}{})
 	return b
 }
 
 type clientContext[T any] struct {
-	client  T
-	target  string
-	timeout time.Duration
+	configSnapshot
+
+	client    T
+	target    string
+	timeout   time.Duration
+	opts      []client.Option // 创建 client 时的参数, 后台刷新时复用
+	createdAt time.Time
+	lastUsed  atomic.Int64 // unix nano
+
+	lock       sync.Mutex
+	refs       int
+	draining   bool
+	closed     bool
+	idle       chan struct{} // 进入 draining 状态且引用计数归零时关闭
+	idleClosed bool
+	destroyed  chan struct{} // closer 执行完毕后关闭
+}
+
+func newClientContext[T any](
+	cli T, snapshot configSnapshot, opts []client.Option,
+) *clientContext[T] {
+	return &clientContext[T]{
+		configSnapshot: snapshot,
+		client:         cli,
+		target:         snapshot.config.Target,
+		timeout:        clientTimeout(&snapshot.config),
+		opts:           opts,
+		createdAt:      time.Now(),
+		idle:           make(chan struct{}),
+		destroyed:      make(chan struct{}),
+	}
+}
+
+// acquire 增加引用计数, 如果 client 已经关闭则返回 false
+func (c *clientContext[T]) acquire() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed {
+		return false
+	}
+	c.refs++
+	return true
+}
+
+// release 减少引用计数
+func (c *clientContext[T]) release() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.refs > 0 {
+		c.refs--
+	}
+	if c.refs == 0 && c.draining {
+		c.notifyIdle()
+	}
+}
+
+// retire 将 client 标记为 draining 状态, 返回一个在引用计数归零时关闭的 channel
+func (c *clientContext[T]) retire() <-chan struct{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.draining = true
+	if c.refs == 0 {
+		c.notifyIdle()
+	}
+	return c.idle
+}
+
+// markClosed 将 client 标记为已关闭, 此后不可再被 acquire, 返回此时尚未归还的引用数。
+// first 为 false 表示 client 之前已经被标记过了, 调用方不应再次关闭它。
+func (c *clientContext[T]) markClosed() (refs int, first bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	first = !c.closed
+	c.closed = true
+	return c.refs, first
+}
+
+// touch 记录 client 最近一次被获取的时间
+func (c *clientContext[T]) touch() {
+	c.lastUsed.Store(time.Now().UnixNano())
+}
+
+func (c *clientContext[T]) notifyIdle() {
+	if !c.idleClosed {
+		c.idleClosed = true
+		close(c.idle)
+	}
 }
 
 func (b *ClientBuffer[T]) count(name string) {
 	metrics.IncrCounter(b.mPrefix+name, 1)
 }
 
+// GetClient 获取 client。注意通过这个函数获取的 client 不计入引用计数, 被替换后最多只保证
+// 在 client 配置的 timeout 时长内不被关闭。如果调用方对 client 的使用时间可能更长 (比如长事务),
+// 请使用 Acquire 或 Do。
 func (b *ClientBuffer[T]) GetClient(
 	name string, opts []client.Option,
 ) (client T, err error) {
-	target, newTimeout := getClientTarget(name)
-	if target == "" {
+	c, err := b.getClientContext(name, opts)
+	if err != nil {
+		return client, err
+	}
+	c.touch()
+	return c.client, nil
+}
+
+// Acquire 获取并占用一个 client, 使用完毕后必须调用 release 归还 (重复调用是安全的)。当 client
+// 配置变化时, 被替换的 client 只有在所有占用都归还之后 (或者超过 drain 期限) 才会被关闭。
+func (b *ClientBuffer[T]) Acquire(
+	name string, opts []client.Option,
+) (client T, release func(), err error) {
+	for {
+		c, err := b.getClientContext(name, opts)
+		if err != nil {
+			return client, nil, err
+		}
+		if c.acquire() {
+			c.touch()
+			return c.client, sync.OnceFunc(c.release), nil
+		}
+		// 拿到的 client 恰好已经被关闭, 重新获取一次
+	}
+}
+
+// Do 占用一个 client 并执行 fn, fn 返回后自动归还
+func (b *ClientBuffer[T]) Do(
+	ctx context.Context, name string, opts []client.Option,
+	fn func(context.Context, T) error,
+) error {
+	cli, release, err := b.Acquire(name, opts)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn(ctx, cli)
+}
+
+func (b *ClientBuffer[T]) getClientContext(
+	name string, opts []client.Option,
+) (*clientContext[T], error) {
+	select {
+	case <-b.done:
+		return nil, fmt.Errorf("%w: get client '%s'", ErrClosed, name)
+	default:
+	}
+
+	cnf := client.Config(name)
+	if cnf == nil || cnf.Target == "" {
 		// 没有配置, 如果历史 client 存在的话返回历史 client, 如果没有的话就只能返回错误了
 		cli, exist := b.clients.Load(name)
 		if !exist {
-			err = fmt.Errorf("client with name '%s' not configured", name)
-			return
+			return nil, fmt.Errorf("client with name '%s' not configured", name)
 		}
-		return cli.client, nil
+		return cli, nil
+	}
+
+	prev, exist := b.clients.Load(name)
+	if !exist {
+		return b.rebuild(name, opts, false)
+	}
+	if b.refresh != nil {
+		// 后台刷新模式下, 配置变化由后台协程处理, 这里直接返回当前 client
+		return prev, nil
+	}
+	fingerprint := b.fingerprint(name, cnf)
+	if changed := b.diffConfig(&prev.configSnapshot, cnf, fingerprint); len(changed) == 0 {
+		return prev, nil
+	}
+	if b.pendingRetry(name, cnf, fingerprint) {
+		// 这份配置已经失败过, 等待重试, 旧的 client 继续服务
+		return prev, nil
 	}
+	return b.rebuild(name, opts, false)
+}
+
+// rebuild 在配置发生变化时重建 client。如果已经存在旧的 client, 新 client 需要通过健康检查
+// 才会替换旧的 client; 否则旧的 client 继续服务, 并按照退避策略安排重试。retrying 表示本次
+// 调用来自重试任务。
+func (b *ClientBuffer[T]) rebuild(
+	name string, opts []client.Option, retrying bool,
+) (*clientContext[T], error) {
+	b.updateLock.Lock()
+	defer b.updateLock.Unlock()
 
-	// 判断一下配置是否发生了变化
-	if prev, exist := b.clients.Load(name); !exist {
-		// should refresh
-	} else if prev.target != target {
-		// should refresh
-	} else {
-		return prev.client, nil
+	if b.isClosed {
+		return nil, fmt.Errorf("%w: rebuild client '%s'", ErrClosed, name)
+	}
+
+	// 加锁之后重新判断一次, 可能已经被其他协程重建过了
+	cnf := client.Config(name)
+	prev, exist := b.clients.Load(name)
+	if cnf == nil || cnf.Target == "" {
+		if !exist {
+			return nil, fmt.Errorf("client with name '%s' not configured", name)
+		}
+		return prev, nil
+	}
+	fingerprint := b.fingerprint(name, cnf)
+	if exist {
+		changed := b.diffConfig(&prev.configSnapshot, cnf, fingerprint)
+		if len(changed) == 0 {
+			b.cancelRetry(name)
+			return prev, nil
+		}
+		if !retrying && b.pendingRetry(name, cnf, fingerprint) {
+			return prev, nil
+		}
+		log.Infof("client %v config changed, fields: %v", name, changed)
 	}
 
 	b.count("clientUpdate.cnt")
 
+	snapshot := configSnapshot{config: *cnf, fingerprint: fingerprint}
 	newClient, err := b.newer(name, opts...)
-	if err != nil {
-		return
+	if err == nil && exist {
+		err = b.probe(name, newClient, cnf)
 	}
-	newClientCtx := &clientContext[T]{
-		client:  newClient,
-		target:  target,
-		timeout: newTimeout,
+	if err != nil {
+		if !exist {
+			return nil, err
+		}
+		b.count("clientCheck.fail")
+		log.Errorf("build new client %v (%+v) failed, keep previous one (%+v): '%v'", name, cnf.Target, prev.target, err)
+		b.scheduleRetry(name, snapshot, opts)
+		return prev, nil
 	}
+	b.cancelRetry(name)
+
+	newClientCtx := newClientContext(newClient, snapshot, opts)
 	prevClientCtx, loaded := b.clients.Swap(name, newClientCtx)
-	if !loaded {
-		return newClient, nil
+	if loaded {
+		b.draining.Store(prevClientCtx, name)
+		go b.drain(name, prevClientCtx)
 	}
+	return newClientCtx, nil
+}
 
-	go func() {
-		time.Sleep(prevClientCtx.timeout)
-		if err := b.closer(prevClientCtx.client); err != nil {
-			b.count("clientDestroy.fail")
-			log.Errorf("close client %v (%+v) failed: '%v'", name, prevClientCtx.target, err)
-			return
-		}
-		b.count("clientDestroy.succ")
-		log.Errorf("close previous redis %v (%+v) success", name, prevClientCtx.target)
-	}()
+// probe 对新建的 client 进行健康检查, 失败时关闭新 client
+func (b *ClientBuffer[T]) probe(name string, cli T, cnf *client.BackendConfig) error {
+	if b.options.healthCheck == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.options.healthCheckTimeout)
+	defer cancel()
+
+	err := b.options.healthCheck(ctx, cli)
+	if err == nil {
+		return nil
+	}
 
-	return newClient, nil
+	if closeErr := b.closer(cli); closeErr != nil {
+		log.Errorf("close unhealthy client %v (%+v) failed: '%v'", name, cnf.Target, closeErr)
+	}
+	return fmt.Errorf("health check for client '%s' failed: %w", name, err)
 }
 
-// getClientTarget 获取 client 的目标配置值
-func getClientTarget(name string) (string, time.Duration) {
-	cnf := client.Config(name)
-	if cnf == nil {
-		return "", 0
+// drain 等待被替换的 client 的所有占用方归还之后再关闭它
+func (b *ClientBuffer[T]) drain(name string, prev *clientContext[T]) {
+	start := time.Now()
+	idle := prev.retire()
+
+	// 至少等待一个 client timeout 时长, 兼容通过 GetClient 获取、不计入引用计数的调用方
+	minWait := time.NewTimer(prev.timeout)
+	defer minWait.Stop()
+
+	select {
+	case <-minWait.C:
+	case <-b.done:
+		return // 由 Close 接管
+	}
+
+	deadline := time.NewTimer(b.options.drainDeadline - time.Since(start))
+	defer deadline.Stop()
+
+	select {
+	case <-idle:
+	case <-deadline.C:
+	case <-b.done:
+		return // 由 Close 接管
 	}
+
+	_ = b.destroy(context.Background(), name, prev, start)
+}
+
+// destroy 关闭一个已被替换的 client。如果 client 已经在被其他协程关闭, 则等待其关闭完成或者
+// ctx 结束。
+func (b *ClientBuffer[T]) destroy(
+	ctx context.Context, name string, prev *clientContext[T], start time.Time,
+) error {
+	refs, first := prev.markClosed()
+	if !first {
+		select {
+		case <-prev.destroyed:
+		case <-ctx.Done():
+		}
+		return nil
+	}
+	defer close(prev.destroyed)
+	defer b.draining.Delete(prev)
+
+	if refs > 0 {
+		b.count("clientDrain.timeout")
+		log.Warnf(
+			"drain client %v (%+v) timeout after %v, %d borrower(s) not released yet",
+			name, prev.target, time.Since(start), refs,
+		)
+	}
+	ela := time.Since(start).Milliseconds()
+	b.count("clientDrain.cnt")
+	metrics.IncrCounter(b.mPrefix+"clientDrain.elapseMsSum", ela)
+	metrics.SetGauge(b.mPrefix+"clientDrain.lastElapseMs", ela)
+
+	if err := b.closer(prev.client); err != nil {
+		b.count("clientDestroy.fail")
+		log.Errorf("close client %v (%+v) failed: '%v'", name, prev.target, err)
+		return fmt.Errorf("close client '%s' error: %w", name, err)
+	}
+	b.count("clientDestroy.succ")
+	log.Infof("close previous client %v (%+v) success", name, prev.target)
+	return nil
+}
+
+// clientTimeout 计算被替换的 client 最少需要保留的时长
+func clientTimeout(cnf *client.BackendConfig) time.Duration {
 	timeout := time.Duration(cnf.Timeout) * time.Millisecond
 	if timeout > time.Minute {
 		timeout = time.Minute // 最多一分钟, 不能再多了
 	}
-	return cnf.Target, timeout
+	return timeout
 }
@@ -0,0 +1,109 @@
+package buffer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/server"
+)
+
+// Close 关闭 ClientBuffer 中所有正在服务和等待关闭的 client, 并停止所有后台协程。每个 client
+// 会等待其占用方全部归还, 或者 ctx 结束之后再关闭。Close 之后 GetClient、Acquire 等函数都会
+// 返回 ErrClosed。重复调用是安全的。
+func (b *ClientBuffer[T]) Close(ctx context.Context) error {
+	b.updateLock.Lock()
+	if b.isClosed {
+		b.updateLock.Unlock()
+		return nil
+	}
+	b.isClosed = true
+	close(b.done)
+
+	b.retries.Range(func(name string, r *retryState) bool {
+		r.timer.Stop()
+		b.retries.Delete(name)
+		return true
+	})
+	// 把正在服务的 client 也转为等待关闭的状态
+	b.clients.Range(func(name string, c *clientContext[T]) bool {
+		b.clients.Delete(name)
+		b.draining.Store(c, name)
+		return true
+	})
+	b.updateLock.Unlock()
+
+	if b.refresh != nil {
+		internal.refreshNotifiers.Delete(b.refresh)
+	}
+	internal.buffers.Delete(b)
+	b.routines.Wait()
+
+	lock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	var errs []error
+
+	b.draining.Range(func(c *clientContext[T], name string) bool {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			select {
+			case <-c.retire():
+			case <-ctx.Done():
+			}
+			if err := b.destroy(ctx, name, c, start); err != nil {
+				lock.Lock()
+				errs = append(errs, err)
+				lock.Unlock()
+			}
+		}()
+		return true
+	})
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// CloseAll 关闭进程内所有的 ClientBuffer, 包括 client/redis、client/gorm 等包中的
+func CloseAll(ctx context.Context) error {
+	lock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	var errs []error
+
+	internal.buffers.Range(func(b registeredBuffer, _ struct{}) bool {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := b.Close(ctx); err != nil {
+				lock.Lock()
+				errs = append(errs, err)
+				lock.Unlock()
+			}
+		}()
+		return true
+	})
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// RegisterOnShutdown 在 tRPC server 退出时调用 CloseAll, 最多等待 timeout 时长
+func RegisterOnShutdown(s *server.Server, timeout time.Duration) {
+	registerOnShutdown(s, timeout, CloseAll)
+}
+
+// RegisterOnShutdown 在 tRPC server 退出时关闭当前 ClientBuffer, 最多等待 timeout 时长
+func (b *ClientBuffer[T]) RegisterOnShutdown(s *server.Server, timeout time.Duration) {
+	registerOnShutdown(s, timeout, b.Close)
+}
+
+// registerOnShutdown 在 tRPC server 退出时调用 closer, 最多等待 timeout 时长
+func registerOnShutdown(s *server.Server, timeout time.Duration, closer func(context.Context) error) {
+	s.RegisterOnShutdown(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		_ = closer(ctx)
+	})
+}
@@ -0,0 +1,58 @@
+package buffer
+
+import (
+	"reflect"
+
+	"trpc.group/trpc-go/trpc-go/client"
+)
+
+// fingerprint 计算自定义的配置指纹, 未指定 WithFingerprint 时返回空值
+func (b *ClientBuffer[T]) fingerprint(name string, cnf *client.BackendConfig) string {
+	if f := b.options.fingerprint; f != nil {
+		return f(name, cnf)
+	}
+	return ""
+}
+
+// configSnapshot 记录创建 client 时的配置, 用于判断配置是否发生了变化
+type configSnapshot struct {
+	config      client.BackendConfig
+	fingerprint string // 仅在指定了 WithFingerprint 时有效
+}
+
+// diffConfig 返回发生了变化的配置字段名, 返回空表示无需重建 client
+func (b *ClientBuffer[T]) diffConfig(
+	prev *configSnapshot, cnf *client.BackendConfig, fingerprint string,
+) []string {
+	if b.options.fingerprint != nil {
+		if prev.fingerprint != fingerprint {
+			return []string{"fingerprint"}
+		}
+		return nil
+	}
+	return diffBackendConfig(&prev.config, cnf)
+}
+
+// diffBackendConfig 逐个比较 BackendConfig 的导出字段, 返回值不同的字段名。函数、channel
+// 等无法比较的字段会被忽略。注意这里只返回字段名, 不能把值打出来, 因为可能包含密码。
+func diffBackendConfig(prev, curr *client.BackendConfig) []string {
+	pv := reflect.ValueOf(prev).Elem()
+	cv := reflect.ValueOf(curr).Elem()
+	typ := pv.Type()
+
+	var changed []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+			continue
+		}
+		if !reflect.DeepEqual(pv.Field(i).Interface(), cv.Field(i).Interface()) {
+			changed = append(changed, field.Name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,34 @@
+package buffer
+
+import (
+	"context"
+
+	syncutil "github.com/Andrew-M-C/go.util/sync"
+)
+
+var internal = struct {
+	refreshNotifiers syncutil.Map[chan struct{}, struct{}]
+	buffers          syncutil.Map[registeredBuffer, struct{}]
+}{
+	refreshNotifiers: syncutil.NewMap[chan struct{}, struct{}](),
+	buffers:          syncutil.NewMap[registeredBuffer, struct{}](),
+}
+
+// registeredBuffer 用于在全局注册表中屏蔽 ClientBuffer 的泛型参数
+type registeredBuffer interface {
+	metricsPrefix() string
+	Snapshot() []ClientInfo
+	Close(context.Context) error
+}
+
+const (
+	// ErrClosed 表示 ClientBuffer 已经关闭
+	ErrClosed = E("client buffer closed")
+)
+
+// E 表示内部错误
+type E string
+
+func (e E) Error() string {
+	return string(e)
+}
@@ -0,0 +1,117 @@
+package buffer
+
+import (
+	"context"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/client"
+)
+
+const (
+	// 被替换的 client 等待所有占用方归还的默认最长时间
+	defaultDrainDeadline = 5 * time.Minute
+	// 健康检查的默认超时时间
+	defaultHealthCheckTimeout = 5 * time.Second
+	// 新 client 不可用时的默认重试退避时间
+	defaultMinRetryBackoff = time.Second
+	defaultMaxRetryBackoff = time.Minute
+)
+
+// Option 表示 NewClientBuffer 的额外参数, T 与 ClientBuffer 的 client 类型一致, 类型不一致时
+// 无法通过编译。无法推导 T 的选项需要显式指定, 比如 buffer.WithDrainDeadline[*gorm.DB](time.Minute)
+type Option[T any] func(*options[T])
+
+type options[T any] struct {
+	drainDeadline      time.Duration
+	fingerprint        FingerprintFunc
+	healthCheck        HealthCheckFunc[T]
+	healthCheckTimeout time.Duration
+	refreshInterval    time.Duration
+	minRetryBackoff    time.Duration
+	maxRetryBackoff    time.Duration
+}
+
+func mergeOptions[T any](opts []Option[T]) options[T] {
+	opt := options[T]{
+		drainDeadline:      defaultDrainDeadline,
+		healthCheckTimeout: defaultHealthCheckTimeout,
+		minRetryBackoff:    defaultMinRetryBackoff,
+		maxRetryBackoff:    defaultMaxRetryBackoff,
+	}
+	for _, o := range opts {
+		if o != nil {
+			o(&opt)
+		}
+	}
+	return opt
+}
+
+// WithDrainDeadline 设置被替换的 client 等待所有占用方归还的最长时间, 超时之后无论是否还有
+// 占用方都会强制关闭。默认 5 分钟, 不会短于 client 配置的 timeout。
+//
+// 每次关闭被替换的 client 时上报 clientDrain.cnt (次数) 和 clientDrain.elapseMsSum (等待时长之和,
+// 单位毫秒) 两个计数器, 平均等待时长为同一周期内两者的增量之比; clientDrain.lastElapseMs 为最近
+// 一次的等待时长。
+func WithDrainDeadline[T any](d time.Duration) Option[T] {
+	return func(o *options[T]) {
+		if d > 0 {
+			o.drainDeadline = d
+		}
+	}
+}
+
+// FingerprintFunc 根据 client 配置计算指纹, 指纹发生变化时 client 会被重建
+type FingerprintFunc func(name string, cnf *client.BackendConfig) string
+
+// WithFingerprint 指定自定义的 client 配置指纹函数。默认情况下会比较 client.BackendConfig
+// 的所有字段, 任何一个字段发生变化都会重建 client。
+func WithFingerprint[T any](f FingerprintFunc) Option[T] {
+	return func(o *options[T]) {
+		o.fingerprint = f
+	}
+}
+
+// HealthCheckFunc 对新建的 client 进行健康检查, 返回 error 表示 client 不可用
+type HealthCheckFunc[T any] func(context.Context, T) error
+
+// WithHealthCheck 指定新建 client 的健康检查函数。配置变化后新建的 client 只有通过检查之后
+// 才会替换旧的 client, 否则旧的 client 继续服务, 并按照 WithRetryBackoff 的策略重试。
+func WithHealthCheck[T any](check HealthCheckFunc[T]) Option[T] {
+	return func(o *options[T]) {
+		if check != nil {
+			o.healthCheck = check
+		}
+	}
+}
+
+// WithHealthCheckTimeout 设置健康检查的超时时间, 默认 5 秒
+func WithHealthCheckTimeout[T any](timeout time.Duration) Option[T] {
+	return func(o *options[T]) {
+		if timeout > 0 {
+			o.healthCheckTimeout = timeout
+		}
+	}
+}
+
+// WithRetryBackoff 设置新 client 构建或健康检查失败之后的重试退避时间, 从 minBackoff 开始每次
+// 翻倍, 最多为 maxBackoff。默认为 1 秒至 1 分钟。
+func WithRetryBackoff[T any](minBackoff, maxBackoff time.Duration) Option[T] {
+	return func(o *options[T]) {
+		if minBackoff <= 0 || maxBackoff < minBackoff {
+			return
+		}
+		o.minRetryBackoff = minBackoff
+		o.maxRetryBackoff = maxBackoff
+	}
+}
+
+// WithBackgroundRefresh 开启后台刷新模式: 由后台协程按照 interval 周期, 或者在收到
+// NotifyClientConfigUpdated 通知时检查 client 配置并预先重建 client, GetClient 不再在调用
+// 时同步重建已有的 client。
+func WithBackgroundRefresh[T any](interval time.Duration) Option[T] {
+	return func(o *options[T]) {
+		if interval > 0 {
+			o.refreshInterval = interval
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package buffer
+
+import "time"
+
+// NotifyClientConfigUpdated 通知所有开启了后台刷新的 ClientBuffer: tRPC client 配置已经更新,
+// 需要立即检查并重建 client。通常在调用 trpc.SetupClients 之后调用。
+func NotifyClientConfigUpdated() {
+	internal.refreshNotifiers.Range(func(ch chan struct{}, _ struct{}) bool {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// 已经有一个通知在排队了, 不需要重复通知
+		}
+		return true
+	})
+}
+
+// refreshRoutine 后台刷新模式下, 定时或在收到配置变化通知时检查并重建 client
+func (b *ClientBuffer[T]) refreshRoutine() {
+	defer b.routines.Done()
+
+	tick := time.NewTicker(b.options.refreshInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+		case <-b.refresh:
+		case <-b.done:
+			return
+		}
+		b.refreshAll()
+	}
+}
+
+func (b *ClientBuffer[T]) refreshAll() {
+	b.clients.Range(func(name string, c *clientContext[T]) bool {
+		// 失败时 rebuild 会保留旧的 client 并安排重试, 这里不需要额外处理
+		_, _ = b.rebuild(name, c.opts, false)
+		return true
+	})
+}
@@ -0,0 +1,69 @@
+package buffer
+
+import (
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/client"
+	"trpc.group/trpc-go/trpc-go/log"
+)
+
+// retryState 记录新 client 构建或健康检查失败之后的重试状态
+type retryState struct {
+	configSnapshot
+
+	attempts int
+	timer    *time.Timer
+}
+
+// pendingRetry 判断当前配置是否已经失败过并且正在等待重试
+func (b *ClientBuffer[T]) pendingRetry(name string, cnf *client.BackendConfig, fingerprint string) bool {
+	r, exist := b.retries.Load(name)
+	if !exist {
+		return false
+	}
+	return len(b.diffConfig(&r.configSnapshot, cnf, fingerprint)) == 0
+}
+
+// scheduleRetry 按照指数退避安排一次重试, 需要在 updateLock 内调用
+func (b *ClientBuffer[T]) scheduleRetry(name string, snapshot configSnapshot, opts []client.Option) {
+	attempts := 1
+	if r, exist := b.retries.Load(name); exist {
+		r.timer.Stop()
+		if len(b.diffConfig(&r.configSnapshot, &snapshot.config, snapshot.fingerprint)) == 0 {
+			attempts = r.attempts + 1
+		}
+	}
+
+	backoff := b.options.retryBackoff(attempts)
+	r := &retryState{
+		configSnapshot: snapshot,
+		attempts:       attempts,
+	}
+	r.timer = time.AfterFunc(backoff, func() {
+		b.count("clientCheck.retry")
+		_, _ = b.rebuild(name, opts, true)
+	})
+	b.retries.Store(name, r)
+
+	log.Warnf("client %v will retry after %v, attempt %d", name, backoff, attempts)
+}
+
+// cancelRetry 取消等待中的重试, 需要在 updateLock 内调用
+func (b *ClientBuffer[T]) cancelRetry(name string) {
+	if r, exist := b.retries.Load(name); exist {
+		r.timer.Stop()
+		b.retries.Delete(name)
+	}
+}
+
+// retryBackoff 计算第 attempts 次重试前的等待时间
+func (o *options[T]) retryBackoff(attempts int) time.Duration {
+	backoff := o.minRetryBackoff
+	for i := 1; i < attempts && backoff < o.maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > o.maxRetryBackoff {
+		backoff = o.maxRetryBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,82 @@
+package buffer
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ClientInfo 描述 ClientBuffer 中的一个 client 的状态
+type ClientInfo struct {
+	Name      string     `json:"name"`
+	Target    string     `json:"target"`
+	CreatedAt time.Time  `json:"created_at"`
+	LastUsed  *time.Time `json:"last_used,omitempty"` // 从未被获取过时为 nil
+	Draining  bool       `json:"draining"`            // 已被替换, 等待关闭
+	Refs      int        `json:"refs"`                // 通过 Acquire 占用且尚未归还的数量
+}
+
+// Snapshot 返回当前所有 client 的状态, 包括正在服务的和已被替换、等待关闭的 client
+func (b *ClientBuffer[T]) Snapshot() []ClientInfo {
+	var res []ClientInfo
+	b.clients.Range(func(name string, c *clientContext[T]) bool {
+		res = append(res, c.info(name, false))
+		return true
+	})
+	b.draining.Range(func(c *clientContext[T], name string) bool {
+		res = append(res, c.info(name, true))
+		return true
+	})
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		return res[i].CreatedAt.Before(res[j].CreatedAt)
+	})
+	return res
+}
+
+func (b *ClientBuffer[T]) metricsPrefix() string {
+	return b.mPrefix
+}
+
+func (c *clientContext[T]) info(name string, draining bool) ClientInfo {
+	c.lock.Lock()
+	refs := c.refs
+	c.lock.Unlock()
+
+	info := ClientInfo{
+		Name:      name,
+		Target:    c.target,
+		CreatedAt: c.createdAt,
+		Draining:  draining,
+		Refs:      refs,
+	}
+	if nano := c.lastUsed.Load(); nano > 0 {
+		lastUsed := time.Unix(0, nano)
+		info.LastUsed = &lastUsed
+	}
+	return info
+}
+
+// SnapshotAll 返回进程内所有 ClientBuffer (包括 client/redis、client/gorm 等包中的) 的
+// client 状态, key 为 ClientBuffer 的 metrics 前缀
+func SnapshotAll() map[string][]ClientInfo {
+	res := map[string][]ClientInfo{}
+	internal.buffers.Range(func(b registeredBuffer, _ struct{}) bool {
+		prefix := b.metricsPrefix()
+		res[prefix] = append(res[prefix], b.Snapshot()...)
+		return true
+	})
+	return res
+}
+
+// HandleSnapshot 以 JSON 格式输出 SnapshotAll 的结果, 可以注册到 tRPC admin 中, 比如:
+//
+//	admin.HandleFunc("/cmds/client_buffers", buffer.HandleSnapshot)
+func HandleSnapshot(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(SnapshotAll())
+}
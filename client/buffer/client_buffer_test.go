@@ -0,0 +1,342 @@
+package buffer_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Andrew-M-C/trpc-go-utils/client/buffer"
+	"github.com/smartystreets/goconvey/convey"
+	"trpc.group/trpc-go/trpc-go/client"
+)
+
+var (
+	cv  = convey.Convey
+	so  = convey.So
+	eq  = convey.ShouldEqual
+	gte = convey.ShouldBeGreaterThanOrEqualTo
+
+	isNil  = convey.ShouldBeNil
+	notNil = convey.ShouldNotBeNil
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(m.Run())
+}
+
+type testClient struct {
+	target string
+	closed atomic.Bool
+}
+
+func newTestBuffer(opts ...buffer.Option[*testClient]) *buffer.ClientBuffer[*testClient] {
+	newer := func(name string, _ ...client.Option) (*testClient, error) {
+		return &testClient{target: client.Config(name).Target}, nil
+	}
+	closer := func(c *testClient) error {
+		c.closed.Store(true)
+		return nil
+	}
+	return buffer.NewClientBuffer("test.buffer.", newer, closer, opts...)
+}
+
+// waitFor 轮询直到 cond 返回 true, 超时返回 false
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return true
+}
+
+func closeBuffer(b *buffer.ClientBuffer[*testClient]) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_ = b.Close(ctx)
+}
+
+func setTarget(name, target string) {
+	_ = client.RegisterClientConfig(name, &client.BackendConfig{
+		Target: target,
+	})
+}
+
+func TestAcquire(t *testing.T) {
+	cv("被占用的 client 在归还之后才关闭", t, func() {
+		const name = "trpc.test.buffer.acquire"
+		setTarget(name, "ip://127.0.0.1:1000")
+		b := newTestBuffer()
+		defer closeBuffer(b)
+
+		prev, release, err := b.Acquire(name, nil)
+		so(err, isNil)
+		so(prev.target, eq, "ip://127.0.0.1:1000")
+
+		setTarget(name, "ip://127.0.0.1:2000")
+		curr, err := b.GetClient(name, nil)
+		so(err, isNil)
+		so(curr.target, eq, "ip://127.0.0.1:2000")
+
+		infos := b.Snapshot()
+		so(len(infos), eq, 2)
+		so(infos[0].Draining, eq, true)
+		so(infos[0].Refs, eq, 1)
+		so(prev.closed.Load(), eq, false)
+
+		release()
+		release() // 重复调用应当安全
+		so(waitFor(prev.closed.Load), eq, true)
+		so(curr.closed.Load(), eq, false)
+	})
+
+	cv("超过 drain 期限后强制关闭", t, func() {
+		const name = "trpc.test.buffer.deadline"
+		setTarget(name, "ip://127.0.0.1:1000")
+		b := newTestBuffer(buffer.WithDrainDeadline[*testClient](200 * time.Millisecond))
+		defer closeBuffer(b)
+
+		prev, release, err := b.Acquire(name, nil)
+		so(err, isNil)
+		defer release()
+
+		setTarget(name, "ip://127.0.0.1:2000")
+		start := time.Now()
+		_, err = b.GetClient(name, nil)
+		so(err, isNil)
+
+		so(waitFor(prev.closed.Load), eq, true)
+		so(time.Since(start), gte, 200*time.Millisecond)
+	})
+
+	cv("Do", t, func() {
+		const name = "trpc.test.buffer.do"
+		setTarget(name, "ip://127.0.0.1:1000")
+		b := newTestBuffer()
+		defer closeBuffer(b)
+
+		var target string
+		err := b.Do(context.Background(), name, nil, func(_ context.Context, c *testClient) error {
+			target = c.target
+			return nil
+		})
+		so(err, isNil)
+		so(target, eq, "ip://127.0.0.1:1000")
+	})
+}
+
+func TestConfigChange(t *testing.T) {
+	cv("Target 以外的配置变化也会重建 client", t, func() {
+		const name = "trpc.test.buffer.config"
+		_ = client.RegisterClientConfig(name, &client.BackendConfig{
+			Target:   "ip://127.0.0.1:1000",
+			Password: "111111",
+		})
+		b := newTestBuffer()
+		defer closeBuffer(b)
+
+		prev, err := b.GetClient(name, nil)
+		so(err, isNil)
+		same, err := b.GetClient(name, nil)
+		so(err, isNil)
+		so(same, eq, prev)
+
+		_ = client.RegisterClientConfig(name, &client.BackendConfig{
+			Target:   "ip://127.0.0.1:1000",
+			Password: "222222",
+		})
+		curr, err := b.GetClient(name, nil)
+		so(err, isNil)
+		so(curr == prev, eq, false)
+	})
+
+	cv("自定义指纹", t, func() {
+		const name = "trpc.test.buffer.fingerprint"
+		_ = client.RegisterClientConfig(name, &client.BackendConfig{
+			Target:   "ip://127.0.0.1:1000",
+			Password: "111111",
+		})
+		b := newTestBuffer(buffer.WithFingerprint[*testClient](func(_ string, cnf *client.BackendConfig) string {
+			return cnf.Target
+		}))
+		defer closeBuffer(b)
+
+		prev, err := b.GetClient(name, nil)
+		so(err, isNil)
+
+		_ = client.RegisterClientConfig(name, &client.BackendConfig{
+			Target:   "ip://127.0.0.1:1000",
+			Password: "222222",
+		})
+		curr, err := b.GetClient(name, nil)
+		so(err, isNil)
+		so(curr, eq, prev)
+	})
+}
+
+func TestBackgroundRefresh(t *testing.T) {
+	cv("后台刷新, 健康检查失败时保留旧 client", t, func() {
+		const name = "trpc.test.buffer.background"
+		setTarget(name, "ip://127.0.0.1:1000")
+		rejected := atomic.Int64{}
+		b := newTestBuffer(
+			buffer.WithBackgroundRefresh[*testClient](time.Hour),
+			buffer.WithRetryBackoff[*testClient](time.Hour, time.Hour),
+			buffer.WithHealthCheck(func(_ context.Context, c *testClient) error {
+				if c.target == "ip://127.0.0.1:9999" {
+					rejected.Add(1)
+					return errors.New("bad target")
+				}
+				return nil
+			}),
+		)
+		defer closeBuffer(b)
+
+		prev, err := b.GetClient(name, nil)
+		so(err, isNil)
+
+		setTarget(name, "ip://127.0.0.1:9999")
+		buffer.NotifyClientConfigUpdated()
+		so(waitFor(func() bool { return rejected.Load() > 0 }), eq, true)
+		curr, err := b.GetClient(name, nil)
+		so(err, isNil)
+		so(curr, eq, prev)
+		so(len(b.Snapshot()), eq, 1)
+	})
+
+	cv("后台刷新, 配置变化后重建 client", t, func() {
+		const name = "trpc.test.buffer.background.rebuild"
+		setTarget(name, "ip://127.0.0.1:1000")
+		b := newTestBuffer(buffer.WithBackgroundRefresh[*testClient](time.Hour))
+		defer closeBuffer(b)
+
+		prev, err := b.GetClient(name, nil)
+		so(err, isNil)
+
+		setTarget(name, "ip://127.0.0.1:2000")
+		buffer.NotifyClientConfigUpdated()
+		so(waitFor(prev.closed.Load), eq, true)
+		curr, err := b.GetClient(name, nil)
+		so(err, isNil)
+		so(curr.target, eq, "ip://127.0.0.1:2000")
+	})
+}
+
+func TestHealthCheck(t *testing.T) {
+	cv("健康检查失败时保留旧 client, 并在退避后重试", t, func() {
+		const name = "trpc.test.buffer.healthcheck"
+		setTarget(name, "ip://127.0.0.1:1000")
+
+		healthy := atomic.Bool{}
+		healthy.Store(true)
+		checkCount := atomic.Int64{}
+
+		b := newTestBuffer(
+			buffer.WithRetryBackoff[*testClient](100*time.Millisecond, 100*time.Millisecond),
+			buffer.WithHealthCheck(func(context.Context, *testClient) error {
+				checkCount.Add(1)
+				if !healthy.Load() {
+					return errors.New("unhealthy")
+				}
+				return nil
+			}),
+		)
+		defer closeBuffer(b)
+
+		prev, err := b.GetClient(name, nil)
+		so(err, isNil)
+		so(checkCount.Load(), eq, 0) // 没有旧 client 时不需要检查
+
+		healthy.Store(false)
+		setTarget(name, "ip://127.0.0.1:2000")
+		for i := 0; i < 10; i++ {
+			curr, err := b.GetClient(name, nil)
+			so(err, isNil)
+			so(curr, eq, prev)
+		}
+		so(checkCount.Load(), eq, 1) // 等待重试期间不会重复构建
+
+		healthy.Store(true)
+		so(waitFor(func() bool {
+			curr, err := b.GetClient(name, nil)
+			return err == nil && curr.target == "ip://127.0.0.1:2000"
+		}), eq, true)
+		so(waitFor(prev.closed.Load), eq, true)
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	cv("Snapshot 包含正在服务和等待关闭的 client", t, func() {
+		const name = "trpc.test.buffer.snapshot"
+		setTarget(name, "ip://127.0.0.1:1000")
+		b := newTestBuffer()
+		defer closeBuffer(b)
+
+		_, release, err := b.Acquire(name, nil)
+		so(err, isNil)
+
+		setTarget(name, "ip://127.0.0.1:2000")
+		_, err = b.GetClient(name, nil)
+		so(err, isNil)
+
+		infos := b.Snapshot()
+		so(len(infos), eq, 2)
+		so(infos[0].Target, eq, "ip://127.0.0.1:1000")
+		so(infos[0].Draining, eq, true)
+		so(infos[0].Refs, eq, 1)
+		so(infos[0].LastUsed, notNil)
+		so(infos[1].Target, eq, "ip://127.0.0.1:2000")
+		so(infos[1].Draining, eq, false)
+		so(infos[1].LastUsed, notNil)
+
+		all := buffer.SnapshotAll()
+		so(len(all["test.buffer."]), gte, 2)
+
+		release()
+		so(waitFor(func() bool { return len(b.Snapshot()) == 1 }), eq, true)
+	})
+
+	cv("从未被获取过的 client 不输出 last_used", t, func() {
+		b, err := json.Marshal(buffer.ClientInfo{Name: "test"})
+		so(err, isNil)
+		so(strings.Contains(string(b), "last_used"), eq, false)
+	})
+}
+
+func TestClose(t *testing.T) {
+	cv("Close 关闭所有 client", t, func() {
+		const name = "trpc.test.buffer.close"
+		setTarget(name, "ip://127.0.0.1:1000")
+		b := newTestBuffer()
+
+		prev, release, err := b.Acquire(name, nil)
+		so(err, isNil)
+
+		setTarget(name, "ip://127.0.0.1:2000")
+		curr, err := b.GetClient(name, nil)
+		so(err, isNil)
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			release()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err = b.Close(ctx)
+		so(err, isNil)
+		so(prev.closed.Load(), eq, true)
+		so(curr.closed.Load(), eq, true)
+
+		_, err = b.GetClient(name, nil)
+		so(errors.Is(err, buffer.ErrClosed), eq, true)
+		so(b.Close(ctx), isNil)
+	})
+}
@@ -7,6 +7,7 @@ toolchain go1.23.5
 require (
 	github.com/Andrew-M-C/go.util/sync v0.0.0-20250116061329-8e3db2afac06
 	github.com/Andrew-M-C/trpc-go-utils/metrics v0.0.0-20250116064400-067a5f44757f
+	github.com/smartystreets/goconvey v1.8.1
 	trpc.group/trpc-go/trpc-go v1.0.3
 )
 
@@ -15,10 +16,12 @@ require (
 	github.com/fsnotify/fsnotify v1.9.0 // indirect
 	github.com/golang/mock v1.6.0 // indirect
 	github.com/golang/snappy v1.0.0 // indirect
+	github.com/gopherjs/gopherjs v1.17.2 // indirect
 	github.com/google/flatbuffers v25.2.10+incompatible // indirect
 	github.com/hashicorp/errwrap v1.1.0 // indirect
 	github.com/hashicorp/go-multierror v1.1.1 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/jtolds/gls v4.20.0+incompatible // indirect
 	github.com/lestrrat-go/strftime v1.1.0 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
@@ -26,6 +29,7 @@ require (
 	github.com/petermattis/goid v0.0.0-20240813172612-4fcff4a6cae7 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/rogpeppe/go-internal v1.11.0 // indirect
+	github.com/smarty/assertions v1.15.0 // indirect
 	github.com/spf13/cast v1.7.1 // indirect
 	github.com/valyala/fasthttp v1.61.0 // indirect
 	go.uber.org/atomic v1.11.0 // indirect
@@ -4,18 +4,28 @@ package gorm
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/Andrew-M-C/trpc-go-utils/client/buffer"
 	"gorm.io/gorm"
 	trpcgorm "trpc.group/trpc-go/trpc-database/gorm"
 	"trpc.group/trpc-go/trpc-go/client"
+	"trpc.group/trpc-go/trpc-go/server"
 )
 
-// ClientGetter 动态获取 gorm 实例
+// ClientGetter 动态获取 gorm 实例, 返回值可以传给 Transaction。在 Transaction 的回调中使用回调
+// 传入的 ctx 时, 返回当前事务的 gorm 实例。
 func ClientGetter(
 	name string, opts ...client.Option,
 ) func(context.Context) (*gorm.DB, error) {
 	return func(ctx context.Context) (*gorm.DB, error) {
+		if p := probeFromContext(ctx); p != nil {
+			p.name, p.opts, p.ok = name, opts, true
+			return nil, nil
+		}
+		if tx := txFromContext(ctx, name); tx != nil {
+			return tx.WithContext(ctx), nil
+		}
 		db, err := buff.GetClient(name, opts)
 		if err != nil {
 			return nil, err
@@ -24,9 +34,79 @@ func ClientGetter(
 	}
 }
 
-var buff *buffer.ClientBuffer[*gorm.DB] = buffer.NewClientBuffer(
-	"amc.utils.gorm.", newGorm, closeGorm,
-)
+// ClientAcquirer 返回占用 gorm 实例的函数, 使用完毕后必须调用 release 归还 (重复调用是安全的)。
+// 与 ClientGetter 不同, 被占用的实例在归还之前不会因为配置变化而被关闭, 适用于长事务等场景。
+// 在 Transaction 的回调中使用回调传入的 ctx 时, 返回当前事务的 gorm 实例。
+func ClientAcquirer(
+	name string, opts ...client.Option,
+) func(context.Context) (db *gorm.DB, release func(), err error) {
+	return func(ctx context.Context) (*gorm.DB, func(), error) {
+		if tx := txFromContext(ctx, name); tx != nil {
+			// 事务本身已经占用了实例
+			return tx.WithContext(ctx), func() {}, nil
+		}
+		db, release, err := buff.Acquire(name, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db.WithContext(ctx), release, nil
+	}
+}
+
+// Do 占用 name 对应的 gorm 实例并执行 fn, fn 返回后自动归还。在 Transaction 的回调中使用回调传入
+// 的 ctx 时, fn 得到的是当前事务的 gorm 实例。
+func Do(
+	ctx context.Context, name string, fn func(context.Context, *gorm.DB) error, opts ...client.Option,
+) error {
+	if tx := txFromContext(ctx, name); tx != nil {
+		return fn(ctx, tx.WithContext(ctx))
+	}
+	return buff.Do(ctx, name, opts, func(ctx context.Context, db *gorm.DB) error {
+		return fn(ctx, db.WithContext(ctx))
+	})
+}
+
+var buff *buffer.ClientBuffer[*gorm.DB] = newBuffer(newGorm)
+
+// SetBufferOptions 指定 gorm 实例缓存池的参数, 比如 buffer.WithBackgroundRefresh、
+// buffer.WithHealthCheck 等。默认在配置变化时 ping 新的数据库连接, 失败时继续使用旧的实例,
+// 可以通过 buffer.WithHealthCheck 替换。
+//
+// SetBufferOptions 不是并发安全的, 必须在第一次获取 gorm 实例之前调用, 比如在 main 函数中创建
+// tRPC server 之后。之前创建的实例会被关闭。
+func SetBufferOptions(opts ...buffer.Option[*gorm.DB]) {
+	prev := buff
+	buff = newBuffer(newGorm, opts...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_ = prev.Close(ctx)
+}
+
+func newBuffer(
+	newer func(string, ...client.Option) (*gorm.DB, error), opts ...buffer.Option[*gorm.DB],
+) *buffer.ClientBuffer[*gorm.DB] {
+	opts = append([]buffer.Option[*gorm.DB]{buffer.WithHealthCheck(pingGorm)}, opts...)
+	return buffer.NewClientBuffer("amc.utils.gorm.", newer, closeGorm, opts...)
+}
+
+// Close 关闭所有通过 ClientGetter 创建的 gorm 实例, 在进程退出时调用
+func Close(ctx context.Context) error {
+	return buff.Close(ctx)
+}
+
+// RegisterOnShutdown 在 tRPC server 退出时关闭所有 gorm 实例, 最多等待 timeout 时长
+func RegisterOnShutdown(s *server.Server, timeout time.Duration) {
+	buff.RegisterOnShutdown(s, timeout)
+}
+
+func pingGorm(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("get DB error (%w)", err)
+	}
+	return sqlDB.PingContext(ctx)
+}
 
 func closeGorm(db *gorm.DB) error {
 	sqlDB, err := db.DB()
@@ -37,5 +117,10 @@ func closeGorm(db *gorm.DB) error {
 }
 
 func newGorm(name string, opts ...client.Option) (*gorm.DB, error) {
-	return trpcgorm.NewClientProxy(name, opts...)
+	db, err := trpcgorm.NewClientProxy(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	applyDefaultLogger(db)
+	return db, nil
 }
@@ -0,0 +1,159 @@
+package gorm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/Andrew-M-C/trpc-go-utils/log"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// NewLogger 返回通过本项目 log 包输出结构化日志的 gorm logger, 日志中包含 context 中的 trace ID。
+// ClientGetter 等函数创建的 gorm 实例默认使用 NewLogger(), 可以通过 SetDefaultLogger 替换或关闭。
+func NewLogger(opts ...LoggerOption) gormlogger.Interface {
+	return &logger{
+		opts: mergeLoggerOptions(opts),
+	}
+}
+
+// defaultLogger 为 nil 时表示未调用过 SetDefaultLogger, 使用 NewLogger()
+var defaultLogger atomic.Pointer[gormlogger.Interface]
+
+// SetDefaultLogger 指定 ClientGetter 等函数之后新建的 gorm 实例使用的 logger, 默认为 NewLogger()。
+// 传入 nil 时不替换 logger, 即使用 trpc-database/gorm 根据配置创建的 gorm logger。
+func SetDefaultLogger(l gormlogger.Interface) {
+	defaultLogger.Store(&l)
+}
+
+// applyDefaultLogger 按照 SetDefaultLogger 的设置替换 db 的 logger
+func applyDefaultLogger(db *gorm.DB) {
+	p := defaultLogger.Load()
+	if p == nil {
+		db.Logger = NewLogger()
+		return
+	}
+	if *p != nil {
+		db.Logger = *p
+	}
+}
+
+type logger struct {
+	opts *loggerOptions
+}
+
+var (
+	_ gormlogger.Interface = (*logger)(nil)
+	_ gorm.ParamsFilter    = (*logger)(nil)
+)
+
+func (l *logger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	opts := *l.opts
+	opts.level = level
+	return &logger{opts: &opts}
+}
+
+func (l *logger) Info(ctx context.Context, msg string, data ...any) {
+	if l.opts.level >= gormlogger.Info {
+		log.New().Format(msg, data...).InfoContext(ctx)
+	}
+}
+
+func (l *logger) Warn(ctx context.Context, msg string, data ...any) {
+	if l.opts.level >= gormlogger.Warn {
+		log.New().Format(msg, data...).WarnContext(ctx)
+	}
+}
+
+func (l *logger) Error(ctx context.Context, msg string, data ...any) {
+	if l.opts.level >= gormlogger.Error {
+		log.New().Format(msg, data...).ErrorContext(ctx)
+	}
+}
+
+// Trace 在每条语句执行之后调用。出错时打印 error 日志, 耗时超过阈值时打印 warn 日志, 日志级别为
+// Info 时打印所有语句。
+func (l *logger) Trace(
+	ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error,
+) {
+	if l.opts.level <= gormlogger.Silent {
+		return
+	}
+	ela := time.Since(begin)
+
+	newLog := func(txt string) *log.Logger {
+		sql, rows := fc()
+		return log.New().
+			Text(txt).
+			With("SQL", sql).
+			With("ROWS", rows).
+			With("ELAPSE_MS", ela.Milliseconds())
+	}
+
+	switch {
+	case err != nil && l.opts.level >= gormlogger.Error &&
+		!(l.opts.ignoreRecordNotFound && errors.Is(err, gorm.ErrRecordNotFound)):
+		newLog("gorm sql error").Err(err).ErrorContext(ctx)
+
+	case l.opts.slowThreshold > 0 && ela > l.opts.slowThreshold && l.opts.level >= gormlogger.Warn:
+		newLog("gorm slow sql").WarnContext(ctx)
+
+	case l.opts.level >= gormlogger.Info:
+		newLog("gorm sql").InfoContext(ctx)
+	}
+}
+
+// ParamsFilter 实现 gorm.ParamsFilter, 对日志中 SQL 的参数脱敏, 参见 WithParamsRedactor。指定
+// WithSQLParams 时不脱敏。
+func (l *logger) ParamsFilter(_ context.Context, sql string, params ...any) (string, []any) {
+	if l.opts.showParams || len(params) == 0 {
+		return sql, params
+	}
+	res := make([]any, len(params))
+	for i, p := range params {
+		res[i] = l.opts.redactor(p)
+	}
+	return sql, res
+}
+
+// RedactParam 是默认的参数脱敏函数: 数字、布尔、时间和 nil 保持原样, 字符串和 []byte 替换为
+// "***(长度)", 其他类型替换为 "***"。实现了 driver.Valuer 的参数按照 Value 的返回值处理。
+func RedactParam(param any) any {
+	switch v := param.(type) {
+	case nil, bool, time.Time, *time.Time:
+		return param
+	case string:
+		return fmt.Sprintf("***(%d)", len(v))
+	case []byte:
+		return fmt.Sprintf("***(%d)", len(v))
+	case driver.Valuer:
+		val, err := v.Value()
+		if err != nil {
+			return "***"
+		}
+		if _, ok := val.(driver.Valuer); ok {
+			return "***"
+		}
+		return RedactParam(val)
+	}
+
+	rv := reflect.ValueOf(param)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Bool:
+		return param
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil
+		}
+		return RedactParam(rv.Elem().Interface())
+	default:
+		return "***"
+	}
+}
@@ -0,0 +1,70 @@
+package gorm
+
+import (
+	"time"
+
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// LoggerOption 表示 NewLogger 的额外参数
+type LoggerOption func(*loggerOptions)
+
+type loggerOptions struct {
+	level                gormlogger.LogLevel
+	slowThreshold        time.Duration
+	ignoreRecordNotFound bool
+	showParams           bool
+	redactor             func(any) any
+}
+
+// WithLogLevel 指定日志级别, 默认为 gormlogger.Warn, 即只打印错误和慢查询
+func WithLogLevel(level gormlogger.LogLevel) LoggerOption {
+	return func(o *loggerOptions) {
+		o.level = level
+	}
+}
+
+// WithSlowThreshold 指定慢查询的阈值, 默认 200ms。小于等于零时不打印慢查询日志
+func WithSlowThreshold(d time.Duration) LoggerOption {
+	return func(o *loggerOptions) {
+		o.slowThreshold = d
+	}
+}
+
+// WithRecordNotFoundError 将 gorm.ErrRecordNotFound 也作为错误打印日志, 默认不打印
+func WithRecordNotFoundError() LoggerOption {
+	return func(o *loggerOptions) {
+		o.ignoreRecordNotFound = false
+	}
+}
+
+// WithSQLParams 在日志的 SQL 中包含参数的原始值, 不脱敏
+func WithSQLParams() LoggerOption {
+	return func(o *loggerOptions) {
+		o.showParams = true
+	}
+}
+
+// WithParamsRedactor 指定日志中 SQL 参数的脱敏函数, 默认为 RedactParam
+func WithParamsRedactor(f func(param any) any) LoggerOption {
+	return func(o *loggerOptions) {
+		if f != nil {
+			o.redactor = f
+		}
+	}
+}
+
+func mergeLoggerOptions(opts []LoggerOption) *loggerOptions {
+	o := &loggerOptions{
+		level:                gormlogger.Warn,
+		slowThreshold:        200 * time.Millisecond,
+		ignoreRecordNotFound: true,
+		redactor:             RedactParam,
+	}
+	for _, f := range opts {
+		if f != nil {
+			f(o)
+		}
+	}
+	return o
+}
@@ -0,0 +1,198 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/smartystreets/goconvey/convey"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	trpclog "trpc.group/trpc-go/trpc-go/log"
+)
+
+// captureLogger 记录通过 trpc log 输出的日志
+type captureLogger struct {
+	trpclog.Logger
+
+	lock sync.Mutex
+	logs []string
+}
+
+func (c *captureLogger) add(level string, args []any) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.logs = append(c.logs, level+" "+fmt.Sprint(args...))
+}
+
+func (c *captureLogger) Info(args ...any)  { c.add("INFO", args) }
+func (c *captureLogger) Warn(args ...any)  { c.add("WARN", args) }
+func (c *captureLogger) Error(args ...any) { c.add("ERROR", args) }
+
+func (c *captureLogger) take() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	res := c.logs
+	c.logs = nil
+	return res
+}
+
+func captureLogs(t *testing.T) *captureLogger {
+	prev := trpclog.GetDefaultLogger()
+	c := &captureLogger{Logger: prev}
+	trpclog.SetLogger(c)
+	t.Cleanup(func() { trpclog.SetLogger(prev) })
+	return c
+}
+
+func TestLogger(t *testing.T) {
+	ctx := context.Background()
+	fc := func() (string, int64) { return "SELECT * FROM t_user WHERE id = 1", 1 }
+
+	cv("慢查询阈值", t, func() {
+		logs := captureLogs(t)
+		l := NewLogger(WithSlowThreshold(100 * time.Millisecond))
+
+		l.Trace(ctx, time.Now().Add(-10*time.Millisecond), fc, nil)
+		so(len(logs.take()), eq, 0)
+
+		l.Trace(ctx, time.Now().Add(-200*time.Millisecond), fc, nil)
+		res := logs.take()
+		so(len(res), eq, 1)
+		so(strings.HasPrefix(res[0], "WARN "), isTrue)
+		so(strings.Contains(res[0], "gorm slow sql"), isTrue)
+
+		// 阈值小于等于零时不打印慢查询日志
+		l = NewLogger(WithSlowThreshold(0))
+		l.Trace(ctx, time.Now().Add(-time.Hour), fc, nil)
+		so(len(logs.take()), eq, 0)
+	})
+
+	cv("默认不打印记录不存在的错误", t, func() {
+		logs := captureLogs(t)
+
+		l := NewLogger()
+		l.Trace(ctx, time.Now(), fc, gorm.ErrRecordNotFound)
+		so(len(logs.take()), eq, 0)
+
+		l.Trace(ctx, time.Now(), fc, errors.New("db error"))
+		res := logs.take()
+		so(len(res), eq, 1)
+		so(strings.HasPrefix(res[0], "ERROR "), isTrue)
+
+		l = NewLogger(WithRecordNotFoundError())
+		l.Trace(ctx, time.Now(), fc, fmt.Errorf("wrapped: %w", gorm.ErrRecordNotFound))
+		so(len(logs.take()), eq, 1)
+
+		// Silent 时什么都不打印
+		l.LogMode(gormlogger.Silent).Trace(ctx, time.Now(), fc, errors.New("db error"))
+		so(len(logs.take()), eq, 0)
+	})
+
+	cv("参数脱敏", t, func() {
+		now := time.Now()
+		name := "Alice"
+		cases := []struct {
+			param any
+			want  any
+		}{
+			{int64(42), int64(42)},
+			{uint8(7), uint8(7)},
+			{3.5, 3.5},
+			{true, true},
+			{nil, nil},
+			{now, now},
+			{"secret", "***(6)"},
+			{[]byte("token"), "***(5)"},
+			{&name, "***(5)"},
+			{(*string)(nil), nil},
+			{sql.NullString{String: "abc", Valid: true}, "***(3)"},
+			{sql.NullInt64{Int64: 9, Valid: true}, int64(9)},
+			{struct{ A int }{1}, "***"},
+		}
+
+		l := NewLogger().(gorm.ParamsFilter)
+		params := make([]any, 0, len(cases))
+		for _, c := range cases {
+			params = append(params, c.param)
+		}
+		q, res := l.ParamsFilter(ctx, "SQL", params...)
+		so(q, eq, "SQL")
+		so(len(res), eq, len(cases))
+		for i, c := range cases {
+			so(res[i], convey.ShouldResemble, c.want)
+		}
+
+		// 不修改调用方的参数
+		so(params[6], eq, "secret")
+
+		_, res = NewLogger(WithSQLParams()).(gorm.ParamsFilter).ParamsFilter(ctx, "SQL", "secret")
+		so(res, convey.ShouldResemble, []any{"secret"})
+
+		upper := func(p any) any { return strings.ToUpper(fmt.Sprint(p)) }
+		_, res = NewLogger(WithParamsRedactor(upper)).(gorm.ParamsFilter).ParamsFilter(ctx, "SQL", "secret")
+		so(res, convey.ShouldResemble, []any{"SECRET"})
+	})
+
+	cv("日志中的 SQL 包含脱敏之后的参数", t, func() {
+		logs := captureLogs(t)
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+			Logger: NewLogger(WithLogLevel(gormlogger.Info)),
+		})
+		so(err, isNil)
+		defer func() { _ = closeGorm(db) }()
+		so(db.AutoMigrate(&user{}), isNil)
+		logs.take()
+
+		so(db.Create(&user{ID: 1, Name: "Alice"}).Error, isNil)
+		res := logs.take()
+		so(len(res), eq, 1)
+		so(strings.Contains(res[0], "***(5)"), isTrue)
+		so(strings.Contains(res[0], "Alice"), eq, false)
+	})
+}
+
+func TestDefaultLogger(t *testing.T) {
+	newDB := func(l gormlogger.Interface) *gorm.DB {
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: l})
+		so(err, isNil)
+		return db
+	}
+
+	cv("默认使用 NewLogger", t, func() {
+		db := newDB(gormlogger.Default)
+		defer func() { _ = closeGorm(db) }()
+
+		applyDefaultLogger(db)
+		_, isOurs := db.Logger.(*logger)
+		so(isOurs, isTrue)
+	})
+
+	cv("SetDefaultLogger 替换 logger", t, func() {
+		db := newDB(gormlogger.Default)
+		defer func() { _ = closeGorm(db) }()
+		defer defaultLogger.Store(nil)
+
+		l := gormlogger.Default.LogMode(gormlogger.Info)
+		SetDefaultLogger(l)
+		applyDefaultLogger(db)
+		so(db.Logger, eq, l)
+	})
+
+	cv("SetDefaultLogger(nil) 保留 trpc-database/gorm 配置的 logger", t, func() {
+		trpcLogger := gormlogger.Default.LogMode(gormlogger.Error)
+		db := newDB(trpcLogger)
+		defer func() { _ = closeGorm(db) }()
+		defer defaultLogger.Store(nil)
+
+		SetDefaultLogger(nil)
+		applyDefaultLogger(db)
+		so(db.Logger, eq, trpcLogger)
+	})
+}
@@ -0,0 +1,57 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Andrew-M-C/trpc-go-utils/client/buffer"
+	"gorm.io/gorm"
+)
+
+func TestBufferOptions(t *testing.T) {
+	cv("默认 ping 新的实例, 通过之后替换旧的实例", t, func() {
+		useSQLite(t)
+		name := newSQLiteClient(t)
+
+		first, err := ClientGetter(name)(context.Background())
+		so(err, isNil)
+		so(insertUser(context.Background(), name, user{ID: 1, Name: "Alice"}), isNil)
+
+		setSQLiteTarget(name, filepath.Join(t.TempDir(), "other.db"))
+		curr, err := ClientGetter(name)(context.Background())
+		so(err, isNil)
+		so(curr.Statement.ConnPool == first.Statement.ConnPool, eq, false)
+		so(countUsers(name), eq, 0)
+	})
+
+	cv("健康检查失败时继续使用旧的实例", t, func() {
+		checks := atomic.Int64{}
+		useSQLite(t,
+			buffer.WithHealthCheck(func(context.Context, *gorm.DB) error {
+				checks.Add(1)
+				return errors.New("unhealthy")
+			}),
+			buffer.WithRetryBackoff[*gorm.DB](time.Hour, time.Hour),
+		)
+		name := newSQLiteClient(t)
+		so(insertUser(context.Background(), name, user{ID: 1, Name: "Alice"}), isNil)
+
+		setSQLiteTarget(name, filepath.Join(t.TempDir(), "other.db"))
+		so(countUsers(name), eq, 1)
+		so(checks.Load(), eq, 1)
+	})
+
+	cv("SetBufferOptions 替换并关闭之前的 buffer", t, func() {
+		prev := buff
+		SetBufferOptions()
+		defer SetBufferOptions()
+
+		so(buff == prev, eq, false)
+		_, err := prev.GetClient("trpc.gorm.test.closed", nil)
+		so(errors.Is(err, buffer.ErrClosed), isTrue)
+	})
+}
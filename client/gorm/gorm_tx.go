@@ -0,0 +1,97 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Andrew-M-C/go.util/runtime/caller"
+	"github.com/Andrew-M-C/trpc-go-utils/recovery"
+	"gorm.io/gorm"
+	"trpc.group/trpc-go/trpc-go/client"
+)
+
+// txKey 是事务在 context 中的 key, 以 client 名称区分不同的数据库。配置变化时 buffer 会创建新的
+// gorm 实例, 因此不能以 gorm 实例本身区分。
+type txKey struct {
+	name string
+}
+
+func withTx(ctx context.Context, name string, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{name: name}, tx)
+}
+
+func txFromContext(ctx context.Context, name string) *gorm.DB {
+	tx, _ := ctx.Value(txKey{name: name}).(*gorm.DB)
+	return tx
+}
+
+// getterProbe 用于 Transaction 从 ClientGetter 返回的函数中取得 client 名称和参数, 使得事务与
+// repository 持有的 getter 对应, 而不需要调用方重复填写 client 名称。
+type getterProbe struct {
+	name string
+	opts []client.Option
+	ok   bool
+}
+
+type getterProbeKey struct{}
+
+func probeFromContext(ctx context.Context) *getterProbe {
+	p, _ := ctx.Value(getterProbeKey{}).(*getterProbe)
+	return p
+}
+
+// probeGetter 取得 getter 对应的 client 名称和参数, getter 必须由 ClientGetter 创建
+func probeGetter(getter func(context.Context) (*gorm.DB, error)) (*getterProbe, error) {
+	if getter == nil {
+		return nil, errors.New("nil gorm getter")
+	}
+	p := &getterProbe{}
+	_, _ = getter(context.WithValue(context.Background(), getterProbeKey{}, p))
+	if !p.ok {
+		return nil, errors.New("gorm getter is not created by ClientGetter")
+	}
+	return p, nil
+}
+
+// Transaction 占用 getter 对应的 gorm 实例并在事务中执行 fn, 事务结束之后归还, 因此事务执行期间
+// 即使配置发生变化, 事务也始终使用同一个实例。在 fn 中使用 fn 传入的 ctx 调用同一 client 的
+// ClientGetter、ClientAcquirer 的返回值或 Do 时, 得到的是当前事务的 gorm.DB, 因此跨多个
+// repository 方法的操作可以在同一个事务中执行。getter 必须由 ClientGetter 创建, 否则返回错误。
+//
+// 在 fn 中再次调用 Transaction 时使用 SAVEPOINT, 只有最外层才会提交或回滚, 嵌套调用时 opts 会被
+// 忽略。fn 返回错误或 panic 时回滚事务, panic 会被转为错误返回。
+func Transaction(
+	ctx context.Context, getter func(context.Context) (*gorm.DB, error),
+	fn func(context.Context) error, opts ...TxOption,
+) error {
+	p, err := probeGetter(getter)
+	if err != nil {
+		return err
+	}
+	if tx := txFromContext(ctx, p.name); tx != nil {
+		// gorm 在已经处于事务中时自动使用 SAVEPOINT
+		return tx.WithContext(ctx).Transaction(func(*gorm.DB) error {
+			return callTx(ctx, fn)
+		})
+	}
+
+	o := mergeTxOptions(opts)
+	return buff.Do(ctx, p.name, p.opts, func(ctx context.Context, db *gorm.DB) error {
+		return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return callTx(withTx(ctx, p.name, tx), fn)
+		}, o.txOpts...)
+	})
+}
+
+// callTx 执行 fn, 并将 fn 中的 panic 转为错误返回, 使得 gorm 可以回滚事务
+func callTx(ctx context.Context, fn func(context.Context) error) (err error) {
+	defer recovery.CatchPanic(
+		recovery.WithContext(ctx),
+		recovery.WithErrorLog(),
+		recovery.WithCallback(func(_ context.Context, info any, _ []caller.Caller) {
+			err = fmt.Errorf("panic in transaction: %v", info)
+		}),
+	)
+	return fn(ctx)
+}
@@ -0,0 +1,29 @@
+package gorm
+
+import "database/sql"
+
+// TxOption 表示 Transaction 的额外参数
+type TxOption func(*txOptions)
+
+type txOptions struct {
+	txOpts []*sql.TxOptions
+}
+
+// WithTxOptions 指定事务的隔离级别等参数
+func WithTxOptions(opts *sql.TxOptions) TxOption {
+	return func(o *txOptions) {
+		if opts != nil {
+			o.txOpts = []*sql.TxOptions{opts}
+		}
+	}
+}
+
+func mergeTxOptions(opts []TxOption) *txOptions {
+	o := &txOptions{}
+	for _, f := range opts {
+		if f != nil {
+			f(o)
+		}
+	}
+	return o
+}
@@ -0,0 +1,223 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Andrew-M-C/trpc-go-utils/client/buffer"
+	"github.com/glebarez/sqlite"
+	"github.com/smartystreets/goconvey/convey"
+	"gorm.io/gorm"
+	"trpc.group/trpc-go/trpc-go/client"
+)
+
+var (
+	cv = convey.Convey
+	so = convey.So
+	eq = convey.ShouldEqual
+
+	isNil  = convey.ShouldBeNil
+	notNil = convey.ShouldNotBeNil
+	isTrue = convey.ShouldBeTrue
+)
+
+type user struct {
+	ID   int64
+	Name string
+}
+
+// useSQLite 将 buff 替换为以 SQLite 文件为后端的 buffer, Target 为 "dsn://" 加文件路径
+func useSQLite(t *testing.T, opts ...buffer.Option[*gorm.DB]) {
+	prev := buff
+	buff = newBuffer(func(name string, _ ...client.Option) (*gorm.DB, error) {
+		path := strings.TrimPrefix(client.Config(name).Target, "dsn://")
+		db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+		if err != nil {
+			return nil, err
+		}
+		return db, db.AutoMigrate(&user{})
+	}, opts...)
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = buff.Close(ctx)
+		buff = prev
+	})
+}
+
+// newSQLiteClient 注册一个指向新 SQLite 文件的 client, 返回 client 名称
+func newSQLiteClient(t *testing.T) string {
+	name := "trpc.gorm.test." + strings.ReplaceAll(t.Name(), "/", ".")
+	setSQLiteTarget(name, filepath.Join(t.TempDir(), "test.db"))
+	return name
+}
+
+func setSQLiteTarget(name, path string) {
+	_ = client.RegisterClientConfig(name, &client.BackendConfig{
+		Target: "dsn://" + path,
+	})
+}
+
+func countUsers(name string) int64 {
+	db, err := ClientGetter(name)(context.Background())
+	so(err, isNil)
+	cnt := int64(0)
+	so(db.Model(&user{}).Count(&cnt).Error, isNil)
+	return cnt
+}
+
+func insertUser(ctx context.Context, name string, u user) error {
+	db, err := ClientGetter(name)(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Create(&u).Error
+}
+
+func TestTransaction(t *testing.T) {
+	cv("提交事务", t, func() {
+		useSQLite(t)
+		name := newSQLiteClient(t)
+
+		err := Transaction(context.Background(), ClientGetter(name), func(ctx context.Context) error {
+			if err := insertUser(ctx, name, user{ID: 1, Name: "Alice"}); err != nil {
+				return err
+			}
+			return insertUser(ctx, name, user{ID: 2, Name: "Bob"})
+		})
+		so(err, isNil)
+		so(countUsers(name), eq, 2)
+	})
+
+	cv("返回错误时回滚", t, func() {
+		useSQLite(t)
+		name := newSQLiteClient(t)
+
+		errTest := errors.New("test error")
+		err := Transaction(context.Background(), ClientGetter(name), func(ctx context.Context) error {
+			so(insertUser(ctx, name, user{ID: 1, Name: "Alice"}), isNil)
+			return errTest
+		})
+		so(errors.Is(err, errTest), isTrue)
+		so(countUsers(name), eq, 0)
+	})
+
+	cv("panic 时回滚", t, func() {
+		useSQLite(t)
+		name := newSQLiteClient(t)
+
+		err := Transaction(context.Background(), ClientGetter(name), func(ctx context.Context) error {
+			so(insertUser(ctx, name, user{ID: 1, Name: "Alice"}), isNil)
+			panic("oops")
+		})
+		so(err, notNil)
+		so(countUsers(name), eq, 0)
+	})
+
+	cv("嵌套事务使用 SAVEPOINT", t, func() {
+		useSQLite(t)
+		name := newSQLiteClient(t)
+
+		err := Transaction(context.Background(), ClientGetter(name), func(ctx context.Context) error {
+			if err := insertUser(ctx, name, user{ID: 1, Name: "Alice"}); err != nil {
+				return err
+			}
+			err := Transaction(ctx, ClientGetter(name), func(ctx context.Context) error {
+				so(insertUser(ctx, name, user{ID: 2, Name: "Bob"}), isNil)
+				return errors.New("inner error")
+			})
+			so(err, notNil)
+			return Transaction(ctx, ClientGetter(name), func(ctx context.Context) error {
+				return insertUser(ctx, name, user{ID: 3, Name: "Carol"})
+			})
+		})
+		so(err, isNil)
+		so(countUsers(name), eq, 2)
+
+		db, err := ClientGetter(name)(context.Background())
+		so(err, isNil)
+		u := user{}
+		so(db.First(&u, 2).Error, notNil)
+	})
+
+	cv("事务期间配置变化不影响事务", t, func() {
+		useSQLite(t)
+		name := newSQLiteClient(t)
+		firstPath := strings.TrimPrefix(client.Config(name).Target, "dsn://")
+		first, err := ClientGetter(name)(context.Background())
+		so(err, isNil)
+		sqlDB, err := first.DB()
+		so(err, isNil)
+
+		err = Transaction(context.Background(), ClientGetter(name), func(ctx context.Context) error {
+			setSQLiteTarget(name, filepath.Join(t.TempDir(), "other.db"))
+			// 不在事务中的调用得到新的实例, 事务中的调用仍然得到事务
+			other, err := ClientGetter(name)(context.Background())
+			so(err, isNil)
+			so(other.Statement.ConnPool == first.Statement.ConnPool, eq, false)
+
+			if err := insertUser(ctx, name, user{ID: 1, Name: "Alice"}); err != nil {
+				return err
+			}
+			return Do(ctx, name, func(_ context.Context, db *gorm.DB) error {
+				return db.Create(&user{ID: 2, Name: "Bob"}).Error
+			})
+		})
+		so(err, isNil)
+
+		// 事务提交到旧的实例, 之后旧的实例才被关闭
+		so(countUsers(name), eq, 0)
+		so(waitFor(func() bool { return sqlDB.Ping() != nil }), isTrue)
+
+		old, err := gorm.Open(sqlite.Open(firstPath), &gorm.Config{})
+		so(err, isNil)
+		defer func() { _ = closeGorm(old) }()
+		cnt := int64(0)
+		so(old.Model(&user{}).Count(&cnt).Error, isNil)
+		so(cnt, eq, 2)
+	})
+
+	cv("getter 不是 ClientGetter 创建的时返回错误", t, func() {
+		called := false
+		fn := func(context.Context) error {
+			called = true
+			return nil
+		}
+		getter := func(context.Context) (*gorm.DB, error) { return nil, errors.New("custom") }
+		so(Transaction(context.Background(), getter, fn), notNil)
+		so(Transaction(context.Background(), nil, fn), notNil)
+		so(called, eq, false)
+	})
+
+	cv("事务只对同一 client 的 getter 生效", t, func() {
+		useSQLite(t)
+		name := newSQLiteClient(t)
+		otherName := name + ".other"
+		setSQLiteTarget(otherName, filepath.Join(t.TempDir(), "other.db"))
+
+		err := Transaction(context.Background(), ClientGetter(name), func(ctx context.Context) error {
+			so(insertUser(ctx, otherName, user{ID: 1, Name: "Alice"}), isNil)
+			return errors.New("rollback")
+		})
+		so(err, notNil)
+		so(countUsers(name), eq, 0)
+		so(countUsers(otherName), eq, 1)
+	})
+}
+
+// waitFor 轮询直到 cond 返回 true, 超时返回 false
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return true
+}
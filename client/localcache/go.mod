@@ -1,18 +1,65 @@
 module github.com/Andrew-M-C/trpc-go-utils/client/localcache
 
-go 1.22
+go 1.23.5
 
 require (
+	github.com/Andrew-M-C/trpc-go-utils/concurrent v0.0.0-20261018052536-8962249bc152
+	github.com/Andrew-M-C/trpc-go-utils/metrics v0.0.0-20250116064400-067a5f44757f
 	github.com/smartystreets/goconvey v1.8.1
 	trpc.group/trpc-go/trpc-database/localcache v1.0.0
+	trpc.group/trpc-go/trpc-go v1.0.3
 )
 
 require (
+	github.com/Andrew-M-C/go.jsonvalue v1.4.2 // indirect
+	github.com/Andrew-M-C/go.objectid v1.0.3 // indirect
+	github.com/Andrew-M-C/go.util/log v0.0.0-20251111084840-655d831cc1cf // indirect
+	github.com/Andrew-M-C/go.util/runtime v0.0.0-20251120101424-fd2377cf6964 // indirect
+	github.com/Andrew-M-C/go.util/sync v0.0.0-20250116061329-8e3db2afac06 // indirect
+	github.com/Andrew-M-C/go.util/unsafe v0.0.0-20250116061329-8e3db2afac06 // indirect
+	github.com/Andrew-M-C/trpc-go-utils/log v0.0.0-20250918061229-7193c133ae97 // indirect
+	github.com/Andrew-M-C/trpc-go-utils/plugin v0.0.0-20250116064239-4d93d6161885 // indirect
+	github.com/Andrew-M-C/trpc-go-utils/recovery v0.0.0-20250918061229-7193c133ae97 // indirect
+	github.com/BurntSushi/toml v1.5.0 // indirect
 	github.com/RussellLuo/timingwheel v0.0.0-20191022104228-f534fd34a762 // indirect
+	github.com/andybalholm/brotli v1.1.1 // indirect
 	github.com/cespare/xxhash v1.1.0 // indirect
+	github.com/fsnotify/fsnotify v1.9.0 // indirect
+	github.com/go-playground/form/v4 v4.2.0 // indirect
+	github.com/golang/snappy v1.0.0 // indirect
+	github.com/google/flatbuffers v25.2.10+incompatible // indirect
 	github.com/gopherjs/gopherjs v1.17.2 // indirect
+	github.com/hashicorp/errwrap v1.1.0 // indirect
+	github.com/hashicorp/go-multierror v1.1.1 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/jtolds/gls v4.20.0+incompatible // indirect
+	github.com/klauspost/compress v1.18.0 // indirect
+	github.com/lestrrat-go/strftime v1.1.0 // indirect
+	github.com/mitchellh/mapstructure v1.5.0 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/panjf2000/ants/v2 v2.11.3 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/shopspring/decimal v1.4.0 // indirect
 	github.com/smarty/assertions v1.15.0 // indirect
 	github.com/spaolacci/murmur3 v1.1.0 // indirect
+	github.com/spf13/cast v1.7.1 // indirect
 	github.com/stretchr/testify v1.10.0 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fasthttp v1.61.0 // indirect
+	github.com/valyala/fastrand v1.1.0 // indirect
+	go.mongodb.org/mongo-driver v1.17.1 // indirect
+	go.uber.org/atomic v1.11.0 // indirect
+	go.uber.org/automaxprocs v1.6.0 // indirect
+	go.uber.org/multierr v1.11.0 // indirect
+	go.uber.org/zap v1.27.0 // indirect
+	golang.org/x/exp v0.0.0-20250106191152-7588d65b2ba8 // indirect
+	golang.org/x/net v0.39.0 // indirect
+	golang.org/x/sync v0.13.0 // indirect
+	golang.org/x/sys v0.32.0 // indirect
+	golang.org/x/text v0.24.0 // indirect
+	google.golang.org/protobuf v1.36.6 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+	trpc.group/trpc-go/tnet v1.0.1 // indirect
+	trpc.group/trpc/trpc-protocol/pb/go/trpc v1.0.0 // indirect
 )
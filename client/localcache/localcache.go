@@ -2,12 +2,17 @@ package localcache
 
 import (
 	"context"
+	"io"
+	"strings"
 	"time"
 
 	"trpc.group/trpc-go/trpc-database/localcache"
 )
 
-// Cache 泛型化封装 trpc 官方的 localcache.Cache 类型
+// Cache 泛型化的本地缓存, 接口与 trpc 官方的 localcache.Cache 类型一致。
+//
+// 与 trpc 官方实现不同, 过期时间精确到 time.Duration 而不是取整到秒; 各 Load 方法保证同一个 key 同时最多只有一次加载, 并发的调用会等待
+// 并共享这次加载的结果或错误; MGet 系列方法中与其他调用重叠的 key 也会被合并。
 type Cache[T any] interface {
 	Get(key string) (T, bool)
 	Set(key string, value T) bool
@@ -26,13 +31,27 @@ type Cache[T any] interface {
 	MGetWithCustomLoad(ctx context.Context, keys []string, customLoad MLoadFunc[T], ttl time.Duration) (map[string]T, error)
 }
 
+// StatsGetter 获取缓存的统计数据。为了不影响 Cache 的其他实现 (如 mock), Stats 不在 Cache 接口中,
+// New 和 New2 返回的缓存都实现了这个接口, 可以通过类型断言获取:
+//
+//	if sg, ok := cache.(localcache.StatsGetter); ok {
+//		st := sg.Stats()
+//	}
+type StatsGetter interface {
+	Stats() Stats
+}
+
+// Snapshotter 导出和恢复缓存的快照, 与 StatsGetter 一样不在 Cache 接口中, New 和 New2 返回的缓存
+// 都实现了这个接口。Dump 以 WithSnapshotCodec 指定的方式写入所有未过期的元素, Restore 读取 Dump
+// 的结果并写入缓存, 已经过期的元素会被跳过。
+type Snapshotter interface {
+	Dump(w io.Writer) error
+	Restore(r io.Reader) error
+}
+
 // New generate a cache object
 func New[T any](opts ...Option) Cache[T] {
-	cache := localcache.New(opts...)
-	c := &cacheImpl[T]{
-		cache: cache,
-	}
-	return c
+	return newStringCache[T](mergeOptions(opts))
 }
 
 const (
@@ -68,79 +87,198 @@ type Item[T any] struct {
 }
 
 // Option parameter tool function
-type Option = localcache.Option
+//
+// 不兼容的变更: 早期版本中 Option 是 trpc localcache.Option 的别名, 现在是本包独立的类型, 不再
+// 接受 trpc 的 localcache.WithXxx。迁移时改用本包的同名函数即可, 注意以下差异:
+//   - WithExpiration 和 WithDelay 的参数为 time.Duration, 而不是秒数;
+//   - WithLoad、WithMLoad、WithOnDel 和 WithOnExpire 的回调使用类型参数 T, 而不是 any;
+//   - WithSettingTimeout 不再生效, Set 系列方法总是同步写入。
+type Option func(*options)
+
+type options struct {
+	capacity   int
+	expiration time.Duration
+	delay      time.Duration
+
+	load     any // LoadFunc[T]
+	mload    any // MLoadFunc[T]
+	onDel    any // ItemCallBackFunc[T]
+	onExpire any // ItemCallBackFunc[T]
+
+	syncDelFlag    bool
+	settingTimeout time.Duration
+
+	refreshAhead float64
+	maxStale     time.Duration
+	loadTimeout  time.Duration
+
+	metricsPrefix string
+
+	cost    any // func(T) int64
+	maxCost int64
+	hasher  any // func(K) uint64
+
+	snapshotCodec Codec
+	snapshotFile  string
+}
+
+const (
+	defaultCapacity = 1 << 20
+	// 后台刷新时加载函数的默认超时时间
+	defaultLoadTimeout = 5 * time.Second
+	// 过期数据的清理间隔, 只影响内存回收和 OnExpire 回调的及时性, 不影响读取时的过期判断
+	cleanInterval = 100 * time.Millisecond
+)
 
-// WithCapacity sets the maximum number of keys
+func mergeOptions(opts []Option) options {
+	opt := options{
+		capacity:      defaultCapacity,
+		loadTimeout:   defaultLoadTimeout,
+		snapshotCodec: JSONCodec{},
+	}
+	for _, o := range opts {
+		if o != nil {
+			o(&opt)
+		}
+	}
+	return opt
+}
+
+// WithCapacity sets the maximum number of keys. The least recently used key is
+// evicted when exceeded.
 func WithCapacity(capacity int) Option {
-	return localcache.WithCapacity(capacity)
+	return func(o *options) {
+		if capacity > 0 {
+			o.capacity = capacity
+		}
+	}
 }
 
+// WithCost 指定计算元素开销 (如字节数) 的函数, 配合 WithMaxCost 使用。不指定时每个元素的开销为 1。
+func WithCost[T any](cost func(T) int64) Option {
+	return func(o *options) {
+		o.cost = cost
+	}
+}
+
+// WithMaxCost 限制所有元素的开销之和, 超过时按照 LRU 淘汰, 同时使用 TinyLFU 准入策略: 新元素的
+// 近期访问频率低于需要被淘汰的元素时, 拒绝写入 (Set 系列方法返回 false)。
+func WithMaxCost(maxCost int64) Option {
+	return func(o *options) {
+		o.maxCost = max(maxCost, 0)
+	}
+}
+
+// WithDelay keeps expired keys for another duration, during which GetWithStatus
+// still returns them with CacheExpire status.
 func WithDelay(duration time.Duration) Option {
-	return localcache.WithDelay(durationToSec(duration))
+	return func(o *options) {
+		o.delay = max(duration, 0)
+	}
 }
 
+// WithExpiration sets the default ttl used by Set and loading. Non-positive ttl
+// means never expire.
 func WithExpiration(ttl time.Duration) Option {
-	return localcache.WithExpiration(durationToSec(ttl))
+	return func(o *options) {
+		o.expiration = max(ttl, 0)
+	}
 }
 
 func WithLoad[T any](f LoadFunc[T]) Option {
-	return localcache.WithLoad(func(ctx context.Context, key string) (any, error) {
-		return f(ctx, key)
-	})
+	return func(o *options) {
+		o.load = f
+	}
 }
 
 func WithMLoad[T any](f MLoadFunc[T]) Option {
-	return localcache.WithMLoad(func(ctx context.Context, keys []string) (map[string]any, error) {
-		m, err := f(ctx, keys)
-		if err != nil {
-			return nil, err
-		}
-		res := make(map[string]any, len(m))
-		for k, v := range m {
-			res[k] = v
-		}
-		return res, nil
-	})
+	return func(o *options) {
+		o.mload = f
+	}
 }
 
 func WithOnDel[T any](delCallBack ItemCallBackFunc[T]) Option {
-	return localcache.WithOnDel(func(i *localcache.Item) {
-		if i == nil {
-			return
+	return func(o *options) {
+		o.onDel = delCallBack
+	}
+}
+
+func WithOnExpire[T any](expireCallback ItemCallBackFunc[T]) Option {
+	return func(o *options) {
+		o.onExpire = expireCallback
+	}
+}
+
+// WithSettingTimeout is kept for compatibility. Setting is synchronous in this
+// implementation and never times out, New logs a warning when it is specified.
+//
+// Deprecated: Set 系列方法总是同步写入, 不再需要这个参数。
+func WithSettingTimeout(t time.Duration) Option {
+	return func(o *options) {
+		o.settingTimeout = t
+	}
+}
+
+// WithRefreshAhead 开启提前刷新: 元素的剩余有效期低于其 ttl 的 fraction 比例时, GetWithLoad
+// 和 GetWithCustomLoad 依然直接返回缓存值, 同时在后台触发一次重新加载。fraction 取值 (0, 1)。
+func WithRefreshAhead(fraction float64) Option {
+	return func(o *options) {
+		if fraction > 0 && fraction < 1 {
+			o.refreshAhead = fraction
 		}
-		v, ok := i.Value.(T)
-		if !ok {
-			return
+	}
+}
+
+// WithStaleWhileRevalidate 开启过期数据的异步刷新: 元素过期之后的 maxStale 时长内, GetWithLoad
+// 和 GetWithCustomLoad 直接返回过期的值, 同时在后台触发一次重新加载, 而不是阻塞调用方。
+func WithStaleWhileRevalidate(maxStale time.Duration) Option {
+	return func(o *options) {
+		o.maxStale = max(maxStale, 0)
+	}
+}
+
+// WithLoadTimeout 指定后台刷新时加载函数的超时时间, 默认 5 秒。后台刷新不受调用方 ctx 的取消影响,
+// 只受这个超时控制; 超时之后不再等待加载函数返回, 等待同一个 key 的协程会自行重新加载。
+func WithLoadTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		if timeout > 0 {
+			o.loadTimeout = timeout
 		}
-		delCallBack(Item[T]{
-			Flag:  i.Flag,
-			Key:   i.Key,
-			Value: v,
-		})
-	})
+	}
 }
 
-func WithOnExpire[T any](expireCallback ItemCallBackFunc[T]) Option {
-	return localcache.WithOnExpire(func(i *localcache.Item) {
-		if i == nil {
-			return
+// WithMetricsPrefix 定期通过 metrics 上报 Stats 中的数据, 指标名以 prefix 开头, 如
+// "{prefix}.hit"、"{prefix}.miss"、"{prefix}.size" 等
+func WithMetricsPrefix(prefix string) Option {
+	return func(o *options) {
+		if prefix != "" && !strings.HasSuffix(prefix, ".") {
+			prefix += "."
 		}
-		v, ok := i.Value.(T)
-		if !ok {
-			return
+		o.metricsPrefix = prefix
+	}
+}
+
+// WithSnapshotCodec 指定 Dump / Restore 的序列化方式, 默认为 JSONCodec
+func WithSnapshotCodec(codec Codec) Option {
+	return func(o *options) {
+		if codec != nil {
+			o.snapshotCodec = codec
 		}
-		expireCallback(Item[T]{
-			Flag:  i.Flag,
-			Key:   i.Key,
-			Value: v,
-		})
-	})
+	}
 }
 
-func WithSettingTimeout(t time.Duration) Option {
-	return localcache.WithSettingTimeout(t)
+// WithSnapshotFile 在 New 时从 path 恢复数据, 并在 Close 时将数据写入 path, 使得重启之后缓存
+// 不是冷的。请在进程退出时调用 Close。
+func WithSnapshotFile(path string) Option {
+	return func(o *options) {
+		o.snapshotFile = path
+	}
 }
 
+// WithSyncDelFlag deletes an expired key immediately when it is read, ignoring
+// WithDelay.
 func WithSyncDelFlag(flag bool) Option {
-	return localcache.WithSyncDelFlag(flag)
+	return func(o *options) {
+		o.syncDelFlag = flag
+	}
 }
@@ -0,0 +1,129 @@
+package localcache
+
+import (
+	"context"
+	"time"
+
+	"trpc.group/trpc-go/trpc-database/localcache"
+)
+
+// Cache2 与 Cache 相同, 但是 key 可以是任意 comparable 类型, 如 int64 用户 ID 或者由多个字段
+// 组成的结构体, 避免将 key 格式化为字符串的开销。与 Cache 一样, 统计数据和快照需要通过类型断言
+// StatsGetter 和 Snapshotter 获取。
+type Cache2[K comparable, V any] interface {
+	Get(key K) (V, bool)
+	Set(key K, value V) bool
+	Del(key K)
+	Len() int
+	Clear()
+	Close()
+
+	SetWithExpire(key K, value V, ttl time.Duration) bool
+	GetWithStatus(key K) (V, localcache.CachedStatus)
+
+	GetWithLoad(ctx context.Context, key K) (V, error)
+	GetWithCustomLoad(ctx context.Context, key K, customLoad LoadFunc2[K, V], ttl time.Duration) (V, error)
+
+	MGetWithLoad(ctx context.Context, keys []K) (map[K]V, error)
+	MGetWithCustomLoad(ctx context.Context, keys []K, customLoad MLoadFunc2[K, V], ttl time.Duration) (map[K]V, error)
+}
+
+// New2 generate a cache object with comparable key type. Use WithLoad2, WithMLoad2,
+// WithOnDel2 and WithOnExpire2 instead of their string key versions.
+func New2[K comparable, V any](opts ...Option) Cache2[K, V] {
+	return newCache[K, V](mergeOptions(opts))
+}
+
+// LoadFunc2 is the LoadFunc with comparable key type
+type LoadFunc2[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// MLoadFunc2 is the MLoadFunc with comparable key type
+type MLoadFunc2[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// ItemCallBackFunc2 is the ItemCallBackFunc with comparable key type
+type ItemCallBackFunc2[K comparable, V any] func(Item2[K, V])
+
+// Item2 is the Item with comparable key type
+type Item2[K comparable, V any] struct {
+	Flag  localcache.ItemFlag
+	Key   K
+	Value V
+}
+
+func WithLoad2[K comparable, V any](f LoadFunc2[K, V]) Option {
+	return func(o *options) {
+		o.load = f
+	}
+}
+
+func WithMLoad2[K comparable, V any](f MLoadFunc2[K, V]) Option {
+	return func(o *options) {
+		o.mload = f
+	}
+}
+
+func WithOnDel2[K comparable, V any](delCallBack ItemCallBackFunc2[K, V]) Option {
+	return func(o *options) {
+		o.onDel = delCallBack
+	}
+}
+
+func WithOnExpire2[K comparable, V any](expireCallback ItemCallBackFunc2[K, V]) Option {
+	return func(o *options) {
+		o.onExpire = expireCallback
+	}
+}
+
+// WithKeyHasher2 指定 key 的哈希函数, 用于开启了 WithMaxCost 时 TinyLFU 的访问频率统计。字符串和
+// 整数类型的 key 不需要指定; 其他类型的 key 默认格式化为字符串之后再计算哈希, 每次访问都会分配
+// 内存, 建议指定。哈希值应当尽量均匀分布。
+func WithKeyHasher2[K comparable](hasher func(K) uint64) Option {
+	return func(o *options) {
+		o.hasher = hasher
+	}
+}
+
+// stringCache 以 cacheImpl[string, T] 实现 Cache[T], 只需要转换函数类型
+type stringCache[T any] struct {
+	*cacheImpl[string, T]
+}
+
+func newStringCache[T any](opts options) *stringCache[T] {
+	if f, ok := opts.load.(LoadFunc[T]); ok {
+		opts.load = LoadFunc2[string, T](f)
+	}
+	if f, ok := opts.mload.(MLoadFunc[T]); ok {
+		opts.mload = MLoadFunc2[string, T](f)
+	}
+	opts.onDel = toItemCallBackFunc2[T](opts.onDel)
+	opts.onExpire = toItemCallBackFunc2[T](opts.onExpire)
+	return &stringCache[T]{
+		cacheImpl: newCache[string, T](opts),
+	}
+}
+
+func toItemCallBackFunc2[T any](f any) any {
+	cb, ok := f.(ItemCallBackFunc[T])
+	if !ok {
+		return f
+	}
+	return ItemCallBackFunc2[string, T](func(i Item2[string, T]) {
+		cb(Item[T]{
+			Flag:  i.Flag,
+			Key:   i.Key,
+			Value: i.Value,
+		})
+	})
+}
+
+func (c *stringCache[T]) GetWithCustomLoad(
+	ctx context.Context, key string, customLoad LoadFunc[T], ttl time.Duration,
+) (T, error) {
+	return c.cacheImpl.GetWithCustomLoad(ctx, key, LoadFunc2[string, T](customLoad), ttl)
+}
+
+func (c *stringCache[T]) MGetWithCustomLoad(
+	ctx context.Context, keys []string, customLoad MLoadFunc[T], ttl time.Duration,
+) (map[string]T, error) {
+	return c.cacheImpl.MGetWithCustomLoad(ctx, keys, MLoadFunc2[string, T](customLoad), ttl)
+}
@@ -1,140 +1,428 @@
 package localcache
 
 import (
+	"container/heap"
+	"container/list"
 	"context"
+	"errors"
+	"sync"
 	"time"
 
 	"trpc.group/trpc-go/trpc-database/localcache"
+	"trpc.group/trpc-go/trpc-go/log"
 )
 
-type cacheImpl[T any] struct {
-	cache localcache.Cache
+var (
+	errLoadNotSet  = errors.New("localcache: load function not set, please use WithLoad or WithLoad2")
+	errMLoadNotSet = errors.New("localcache: batch load function not set, please use WithMLoad or WithMLoad2")
+)
+
+// entry 表示缓存中的一个元素
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expireAt  int64 // UnixNano, 0 表示不过期
+	refreshAt int64 // UnixNano, 0 表示不需要提前刷新
+	cost      int64
+	elem      *list.Element
+	index     int // 在过期堆中的下标, -1 表示不在堆中
+}
+
+func (e *entry[K, V]) expired(now int64) bool {
+	return e.expireAt > 0 && e.expireAt <= now
+}
+
+// cacheImpl 基于 map + LRU 链表 + 过期时间小顶堆的原生实现, 过期时间精确到纳秒
+type cacheImpl[K comparable, V any] struct {
+	lock   sync.Mutex
+	items  map[K]*entry[K, V]
+	lru    *list.List // 链表头为最近使用的元素
+	expiry expiryHeap[K, V]
+
+	// 以下仅在开启了 WithMaxCost 时使用
+	totalCost int64
+	sketch    *sketch
+	hasher    func(K) uint64
+
+	opts     options
+	cost     func(V) int64
+	load     LoadFunc2[K, V]
+	mload    MLoadFunc2[K, V]
+	onDel    ItemCallBackFunc2[K, V]
+	onExpire ItemCallBackFunc2[K, V]
+	loads    loadGroup[K, V]
+	stats    cacheStats
+
+	stop      chan struct{}
+	closeOnce sync.Once
 }
 
-func (c *cacheImpl[T]) Get(key string) (res T, ok bool) {
-	v, ok := c.cache.Get(key)
+func newCache[K comparable, V any](opts options) *cacheImpl[K, V] {
+	c := &cacheImpl[K, V]{
+		items: map[K]*entry[K, V]{},
+		lru:   list.New(),
+		opts:  opts,
+		stop:  make(chan struct{}),
+	}
+	c.load = typedOption[LoadFunc2[K, V]]("load", opts.load)
+	c.mload = typedOption[MLoadFunc2[K, V]]("mload", opts.mload)
+	c.onDel = typedOption[ItemCallBackFunc2[K, V]]("onDel", opts.onDel)
+	c.onExpire = typedOption[ItemCallBackFunc2[K, V]]("onExpire", opts.onExpire)
+	c.cost = typedOption[func(V) int64]("cost", opts.cost)
+	c.hasher = typedOption[func(K) uint64]("hasher", opts.hasher)
+	if opts.settingTimeout > 0 {
+		log.Warnf("%s WithSettingTimeout is ignored, Set is always synchronous", logPrefix)
+	}
+	if opts.maxCost > 0 {
+		c.sketch = newSketch(opts.capacity)
+	}
+	if opts.snapshotFile != "" {
+		c.restoreFromFile()
+	}
+
+	go c.cleanRoutine()
+	if opts.metricsPrefix != "" {
+		go c.reportRoutine()
+	}
+	return c
+}
+
+// typedOption 将 options 中以 any 保存的函数转为 T。类型不匹配说明调用方使用了错误的泛型参数,
+// 打印日志之后忽略这个参数。
+func typedOption[T any](name string, v any) T {
+	var zero T
+	if v == nil {
+		return zero
+	}
+	f, ok := v.(T)
 	if !ok {
-		return res, false
+		log.Errorf("%s option %s type %T mismatches %T, ignored", logPrefix, name, v, zero)
+		return zero
 	}
-	res, ok = v.(T)
-	return res, ok
+	return f
 }
 
-func (c *cacheImpl[T]) Set(key string, value T) bool {
-	return c.cache.Set(key, value)
+func (c *cacheImpl[K, V]) Get(key K) (res V, ok bool) {
+	res, status := c.GetWithStatus(key)
+	if status != CacheExist {
+		var zero V
+		return zero, false
+	}
+	return res, true
 }
 
-func (c *cacheImpl[T]) Del(key string) {
-	c.cache.Del(key)
+func (c *cacheImpl[K, V]) Set(key K, value V) bool {
+	return c.SetWithExpire(key, value, c.opts.expiration)
 }
 
-func (c *cacheImpl[T]) Len() int {
-	return c.cache.Len()
+func (c *cacheImpl[K, V]) Del(key K) {
+	c.lock.Lock()
+	e, exist := c.items[key]
+	if exist {
+		c.removeLocked(e)
+	}
+	c.lock.Unlock()
+
+	if exist {
+		c.callback(c.onDel, ItemDelete, e)
+	}
 }
 
-func (c *cacheImpl[T]) Clear() {
-	c.cache.Clear()
+func (c *cacheImpl[K, V]) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.items)
 }
 
-func (c *cacheImpl[T]) Close() {
-	c.cache.Close()
+func (c *cacheImpl[K, V]) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.items = map[K]*entry[K, V]{}
+	c.lru.Init()
+	c.expiry = nil
+	c.totalCost = 0
 }
 
-func (c *cacheImpl[T]) SetWithExpire(
-	key string, value T, ttl time.Duration,
+func (c *cacheImpl[K, V]) Close() {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		if c.opts.snapshotFile == "" {
+			return
+		}
+		if err := c.dumpToFile(); err != nil {
+			log.Warnf("%s dump to '%s' error: '%v'", logPrefix, c.opts.snapshotFile, err)
+		}
+	})
+}
+
+// SetWithExpire 写入数据, ttl <= 0 表示不过期
+func (c *cacheImpl[K, V]) SetWithExpire(
+	key K, value V, ttl time.Duration,
 ) bool {
-	sec := durationToSec(ttl)
-	return c.cache.SetWithExpire(key, value, sec)
+	now := time.Now().UnixNano()
+	expireAt, refreshAt := int64(0), int64(0)
+	if ttl > 0 {
+		expireAt = now + int64(ttl)
+		if c.opts.refreshAhead > 0 {
+			refreshAt = expireAt - int64(float64(ttl)*c.opts.refreshAhead)
+		}
+	}
+
+	cost := int64(1)
+	if c.cost != nil {
+		cost = c.cost(value)
+	}
+
+	c.lock.Lock()
+	e, exist := c.items[key]
+	if exist {
+		c.totalCost += cost - e.cost
+		e.value = value
+		e.expireAt = expireAt
+		e.refreshAt = refreshAt
+		e.cost = cost
+		c.lru.MoveToFront(e.elem)
+		c.updateExpiryLocked(e)
+	} else {
+		if c.sketch != nil && !c.admitLocked(key, cost) {
+			c.lock.Unlock()
+			c.stats.rejects.Add(1)
+			return false
+		}
+		e = &entry[K, V]{
+			key:       key,
+			value:     value,
+			expireAt:  expireAt,
+			refreshAt: refreshAt,
+			cost:      cost,
+			index:     -1,
+		}
+		e.elem = c.lru.PushFront(e)
+		c.items[key] = e
+		c.totalCost += cost
+		c.updateExpiryLocked(e)
+	}
+	evicted := c.evictLocked(e)
+	c.lock.Unlock()
+
+	for _, e := range evicted {
+		c.callback(c.onDel, ItemLruDel, e)
+	}
+	return true
 }
 
-func (c *cacheImpl[T]) GetWithStatus(
-	key string,
-) (res T, status localcache.CachedStatus) {
-	v, status := c.cache.GetWithStatus(key)
-	res, ok := v.(T)
-	if !ok {
-		status = CacheNotExist
+// evictLocked 从 LRU 链表尾部淘汰元素, 直至数量和开销都不超过限制。keep 不会被淘汰。
+func (c *cacheImpl[K, V]) evictLocked(keep *entry[K, V]) (evicted []*entry[K, V]) {
+	for len(c.items) > c.opts.capacity || (c.opts.maxCost > 0 && c.totalCost > c.opts.maxCost) {
+		back, _ := c.lru.Back().Value.(*entry[K, V])
+		if back == keep {
+			break
+		}
+		c.removeLocked(back)
+		evicted = append(evicted, back)
 	}
+	return evicted
+}
+
+func (c *cacheImpl[K, V]) GetWithStatus(
+	key K,
+) (res V, status localcache.CachedStatus) {
+	res, status = c.getWithStatus(key, true)
+	c.stats.hit(status == CacheExist)
 	return res, status
 }
 
-func (c *cacheImpl[T]) GetWithLoad(
-	ctx context.Context, key string,
-) (res T, err error) {
-	v, err := c.cache.GetWithLoad(ctx, key)
-	if err != nil {
-		return res, err
+// peek 与 Get 相同, 但是不计入命中统计
+func (c *cacheImpl[K, V]) peek(key K) (res V, ok bool) {
+	res, status := c.getWithStatus(key, false)
+	if status != CacheExist {
+		var zero V
+		return zero, false
 	}
-	res, _ = v.(T)
-	return res, nil
+	return res, true
 }
 
-func (c *cacheImpl[T]) GetWithCustomLoad(
-	ctx context.Context, key string, customLoad LoadFunc[T], ttl time.Duration,
-) (res T, err error) {
-	fu := func(ctx context.Context, key string) (interface{}, error) {
-		return customLoad(ctx, key)
+// getWithStatus 读取数据, record 表示是否计入 TinyLFU 的访问频率
+func (c *cacheImpl[K, V]) getWithStatus(
+	key K, record bool,
+) (res V, status localcache.CachedStatus) {
+	now := time.Now().UnixNano()
+
+	c.lock.Lock()
+	if record {
+		c.recordAccessLocked(key)
+	}
+	e, exist := c.items[key]
+	if !exist {
+		c.lock.Unlock()
+		return res, CacheNotExist
 	}
-	v, err := c.cache.GetWithCustomLoad(ctx, key, fu, durationToSec(ttl))
-	if err != nil {
-		return res, err
+	if !e.expired(now) {
+		c.lru.MoveToFront(e.elem)
+		res = e.value
+		c.lock.Unlock()
+		return res, CacheExist
 	}
-	res, _ = v.(T)
-	return res, nil
+	if !c.opts.syncDelFlag {
+		res = e.value
+		c.lock.Unlock()
+		return res, CacheExpire
+	}
+	c.removeLocked(e)
+	c.lock.Unlock()
+
+	c.callback(c.onExpire, ItemDelete, e)
+	return res, CacheNotExist
 }
 
-func (c *cacheImpl[T]) MGetWithLoad(
-	ctx context.Context, keys []string,
-) (map[string]T, error) {
-	m, err := c.cache.MGetWithLoad(ctx, keys)
-	if err != nil {
-		return nil, err
+func (c *cacheImpl[K, V]) GetWithLoad(
+	ctx context.Context, key K,
+) (res V, err error) {
+	if c.load == nil {
+		return res, errLoadNotSet
 	}
-	return anyMapToT[T](m), nil
+	return c.GetWithCustomLoad(ctx, key, c.load, c.opts.expiration)
 }
 
-func (c *cacheImpl[T]) MGetWithCustomLoad(
-	ctx context.Context, keys []string, customLoad MLoadFunc[T], ttl time.Duration,
-) (map[string]T, error) {
-	fu := func(ctx context.Context, keys []string) (map[string]any, error) {
+func (c *cacheImpl[K, V]) GetWithCustomLoad(
+	ctx context.Context, key K, customLoad LoadFunc2[K, V], ttl time.Duration,
+) (res V, err error) {
+	load := func(ctx context.Context, key K) (res V, err error) {
+		start := time.Now()
+		res, err = customLoad(ctx, key)
+		c.stats.load(start, err)
+		if err != nil {
+			return res, err
+		}
+		c.SetWithExpire(key, res, ttl)
+		return res, nil
+	}
+	res, ok, recorded := c.getOrRefresh(ctx, key, load)
+	if ok {
+		return res, nil
+	}
+	return c.loadOne(ctx, key, load, !recorded)
+}
+
+func (c *cacheImpl[K, V]) MGetWithLoad(
+	ctx context.Context, keys []K,
+) (map[K]V, error) {
+	if c.mload == nil {
+		return nil, errMLoadNotSet
+	}
+	return c.MGetWithCustomLoad(ctx, keys, c.mload, c.opts.expiration)
+}
+
+func (c *cacheImpl[K, V]) MGetWithCustomLoad(
+	ctx context.Context, keys []K, customLoad MLoadFunc2[K, V], ttl time.Duration,
+) (map[K]V, error) {
+	return c.loadMany(ctx, keys, func(ctx context.Context, keys []K) (map[K]V, error) {
+		start := time.Now()
 		m, err := customLoad(ctx, keys)
+		c.stats.load(start, err)
 		if err != nil {
 			return nil, err
 		}
-		return tMapToAny(m), nil
-	}
-	m, err := c.cache.MGetWithCustomLoad(ctx, keys, fu, durationToSec(ttl))
-	if err != nil {
-		return nil, err
+		for k, v := range m {
+			c.SetWithExpire(k, v, ttl)
+		}
+		return m, nil
+	}, true)
+}
+
+func (c *cacheImpl[K, V]) removeLocked(e *entry[K, V]) {
+	c.totalCost -= e.cost
+	delete(c.items, e.key)
+	c.lru.Remove(e.elem)
+	if e.index >= 0 {
+		heap.Remove(&c.expiry, e.index)
 	}
-	return anyMapToT[T](m), nil
 }
 
-func durationToSec(d time.Duration) int64 {
-	if d <= 0 {
-		return 0
+func (c *cacheImpl[K, V]) updateExpiryLocked(e *entry[K, V]) {
+	switch {
+	case e.expireAt == 0 && e.index >= 0:
+		heap.Remove(&c.expiry, e.index)
+	case e.expireAt > 0 && e.index >= 0:
+		heap.Fix(&c.expiry, e.index)
+	case e.expireAt > 0:
+		heap.Push(&c.expiry, e)
 	}
-	if d < time.Second {
-		return 1
+}
+
+func (c *cacheImpl[K, V]) callback(f ItemCallBackFunc2[K, V], flag localcache.ItemFlag, e *entry[K, V]) {
+	c.stats.remove(flag)
+	if f == nil {
+		return
 	}
-	// 四舍五入
-	return int64((d + 500*time.Millisecond) / time.Second)
+	f(Item2[K, V]{
+		Flag:  flag,
+		Key:   e.key,
+		Value: e.value,
+	})
 }
 
-func anyMapToT[T any](m map[string]any) map[string]T {
-	res := make(map[string]T, len(m))
-	for k, v := range m {
-		if vt, ok := v.(T); ok {
-			res[k] = vt
+func (c *cacheImpl[K, V]) cleanRoutine() {
+	tick := time.NewTicker(cleanInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-tick.C:
+			c.cleanExpired()
 		}
 	}
-	return res
 }
 
-func tMapToAny[T any](m map[string]T) map[string]any {
-	res := make(map[string]any, len(m))
-	for k, v := range m {
-		res[k] = v
+// cleanExpired 清理过期时间加上 WithDelay 或 WithStaleWhileRevalidate 之后仍然过期的元素
+func (c *cacheImpl[K, V]) cleanExpired() {
+	deadline := time.Now().UnixNano() - int64(max(c.opts.delay, c.opts.maxStale))
+	var expired []*entry[K, V]
+
+	c.lock.Lock()
+	for len(c.expiry) > 0 && c.expiry[0].expireAt <= deadline {
+		e := c.expiry[0]
+		c.removeLocked(e)
+		expired = append(expired, e)
 	}
-	return res
+	c.lock.Unlock()
+
+	for _, e := range expired {
+		c.callback(c.onExpire, ItemDelete, e)
+	}
+}
+
+// expiryHeap 按照过期时间排序的小顶堆, 实现 heap.Interface
+type expiryHeap[K comparable, V any] []*entry[K, V]
+
+func (h expiryHeap[K, V]) Len() int {
+	return len(h)
+}
+
+func (h expiryHeap[K, V]) Less(i, j int) bool {
+	return h[i].expireAt < h[j].expireAt
+}
+
+func (h expiryHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap[K, V]) Push(x any) {
+	e, _ := x.(*entry[K, V])
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap[K, V]) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*h = old[:n-1]
+	return e
 }
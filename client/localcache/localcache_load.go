@@ -0,0 +1,262 @@
+package localcache
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/Andrew-M-C/trpc-go-utils/concurrent"
+	"trpc.group/trpc-go/trpc-go/log"
+)
+
+// loadCall 表示一个正在进行中的 key 加载
+type loadCall[V any] struct {
+	done  chan struct{}
+	value V
+	found bool
+	err   error
+}
+
+// loadGroup 保证同一个 key 同时最多只有一个加载, 其他协程等待并共享加载结果
+type loadGroup[K comparable, V any] struct {
+	lock  sync.Mutex
+	calls map[K]*loadCall[V]
+}
+
+// claim 登记 keys 的加载, 返回需要由调用方加载的 key, 以及正在被其他协程加载的 key
+func (g *loadGroup[K, V]) claim(keys []K) (owned, waiting map[K]*loadCall[V]) {
+	owned = make(map[K]*loadCall[V], len(keys))
+	waiting = map[K]*loadCall[V]{}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.calls == nil {
+		g.calls = map[K]*loadCall[V]{}
+	}
+	for _, k := range keys {
+		if c, exist := g.calls[k]; exist {
+			if _, mine := owned[k]; !mine {
+				waiting[k] = c
+			}
+			continue
+		}
+		c := &loadCall[V]{
+			done: make(chan struct{}),
+		}
+		g.calls[k] = c
+		owned[k] = c
+	}
+	return owned, waiting
+}
+
+// finish 结束 calls 的加载并唤醒等待的协程
+func (g *loadGroup[K, V]) finish(calls map[K]*loadCall[V], values map[K]V, err error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	for k, c := range calls {
+		if err != nil {
+			c.err = err
+		} else {
+			c.value, c.found = values[k]
+		}
+		delete(g.calls, k)
+		close(c.done)
+	}
+}
+
+// loadOne 读取单个 key, 未命中时通过 loadGroup 加载。record 为 false 表示调用方已经将这次访问计入
+// TinyLFU 的访问频率。
+func (c *cacheImpl[K, V]) loadOne(
+	ctx context.Context, key K, load func(context.Context, K) (V, error), record bool,
+) (res V, err error) {
+	mload := func(ctx context.Context, keys []K) (map[K]V, error) {
+		v, err := load(ctx, keys[0])
+		if err != nil {
+			return nil, err
+		}
+		return map[K]V{keys[0]: v}, nil
+	}
+	for {
+		m, err := c.loadMany(ctx, []K{key}, mload, record)
+		if err != nil {
+			return res, err
+		}
+		if v, exist := m[key]; exist {
+			return v, nil
+		}
+		// 等待的是一个批量加载, 但是其结果中没有这个 key, 重新加载。这次访问已经计过频率了
+		record = false
+	}
+}
+
+// loadMany 批量读取 keys, 未命中的 key 通过 loadGroup 加载。正在被其他协程加载的 key 不会重复
+// 加载, 而是等待其结果。load 需要负责将加载结果写入缓存。record 表示是否将读取计入 TinyLFU 的
+// 访问频率。
+func (c *cacheImpl[K, V]) loadMany(
+	ctx context.Context, keys []K, load func(context.Context, []K) (map[K]V, error), record bool,
+) (map[K]V, error) {
+	res := make(map[K]V, len(keys))
+	var missing []K
+	for _, k := range keys {
+		v, status := c.getWithStatus(k, record)
+		c.stats.hit(status == CacheExist)
+		if status == CacheExist {
+			res[k] = v
+		} else {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) == 0 {
+		return res, nil
+	}
+
+	owned, waiting := c.loads.claim(missing)
+	if len(owned) > 0 {
+		loaded, err := c.loadOwned(ctx, owned, load)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range loaded {
+			res[k] = v
+		}
+	}
+
+	for k, call := range waiting {
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if call.err != nil {
+			return nil, call.err
+		}
+		if call.found {
+			res[k] = call.value
+		}
+	}
+	return res, nil
+}
+
+// loadOwned 加载由当前协程登记的 keys。无论 load 正常返回还是 panic, 都会结束登记并唤醒等待的协程,
+// 否则等待这些 key 的协程会一直阻塞。panic 会被转为错误返回。
+func (c *cacheImpl[K, V]) loadOwned(
+	ctx context.Context, owned map[K]*loadCall[V], load func(context.Context, []K) (map[K]V, error),
+) (loaded map[K]V, err error) {
+	loaded = make(map[K]V, len(owned))
+	defer func() {
+		if e := recover(); e != nil {
+			log.Errorf("%s load panic: %v, stack: %s", logPrefix, e, debug.Stack())
+			err = fmt.Errorf("load panic: %v", e)
+		}
+		c.loads.finish(owned, loaded, err)
+	}()
+
+	toLoad := make([]K, 0, len(owned))
+	for k := range owned {
+		// 登记之前, 其他协程可能刚刚完成了加载
+		if v, ok := c.peek(k); ok {
+			loaded[k] = v
+		} else {
+			toLoad = append(toLoad, k)
+		}
+	}
+	if len(toLoad) == 0 {
+		return loaded, nil
+	}
+
+	m, err := load(ctx, toLoad)
+	for _, k := range toLoad {
+		if v, exist := m[k]; exist {
+			loaded[k] = v
+		}
+	}
+	return loaded, err
+}
+
+// getOrRefresh 在开启了 WithRefreshAhead 或 WithStaleWhileRevalidate 时, 返回可以直接使用的缓存值,
+// 并按需在后台触发一次刷新。ok 为 false 时调用方需要同步加载, recorded 表示这次访问是否已经计入
+// TinyLFU 的访问频率。
+func (c *cacheImpl[K, V]) getOrRefresh(
+	ctx context.Context, key K, load func(context.Context, K) (V, error),
+) (res V, ok, recorded bool) {
+	if c.opts.refreshAhead <= 0 && c.opts.maxStale <= 0 {
+		return res, false, false
+	}
+	now := time.Now().UnixNano()
+	needRefresh := false
+
+	c.lock.Lock()
+	c.recordAccessLocked(key)
+	e, exist := c.items[key]
+	switch {
+	case !exist:
+	case !e.expired(now):
+		c.lru.MoveToFront(e.elem)
+		res, ok = e.value, true
+		needRefresh = e.refreshAt > 0 && now >= e.refreshAt
+	case now < e.expireAt+int64(c.opts.maxStale):
+		res, ok = e.value, true
+		needRefresh = true
+	}
+	c.lock.Unlock()
+
+	if ok {
+		c.stats.hit(true)
+	}
+	if needRefresh {
+		c.refresh(ctx, key, load)
+	}
+	return res, ok, true
+}
+
+// refresh 在后台重新加载 key, 同一个 key 正在加载时不会重复触发。加载受 WithLoadTimeout 限制, 超时之后
+// 即使 load 没有返回也会结束登记, 避免一直占用这个 key。
+func (c *cacheImpl[K, V]) refresh(
+	ctx context.Context, key K, load func(context.Context, K) (V, error),
+) {
+	owned, _ := c.loads.claim([]K{key})
+	if len(owned) == 0 {
+		return
+	}
+	concurrent.Detach(ctx, func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, c.opts.loadTimeout)
+		defer cancel()
+
+		type result struct {
+			value V
+			ok    bool
+			err   error
+		}
+		ch := make(chan result, 1)
+		go func() {
+			r := result{}
+			// load panic 时 err 为 nil 且没有结果, 等待的协程会自行重新加载
+			defer func() {
+				if e := recover(); e != nil {
+					log.Errorf("%s refresh panic: %v, stack: %s", logPrefix, e, debug.Stack())
+				}
+				ch <- r
+			}()
+			r.value, r.err = load(ctx, key)
+			r.ok = r.err == nil
+		}()
+
+		values := map[K]V{}
+		var err error
+		select {
+		case r := <-ch:
+			if r.ok {
+				values[key] = r.value
+			}
+			err = r.err
+		case <-ctx.Done():
+			// 超时同样不返回错误, 等待的协程会使用自己的 ctx 重新加载
+			log.Warnf("%s refresh key '%v' timeout after %v", logPrefix, key, c.opts.loadTimeout)
+		}
+		c.loads.finish(owned, values, err)
+	})
+}
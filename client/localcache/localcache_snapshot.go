@@ -0,0 +1,142 @@
+package localcache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/log"
+)
+
+const (
+	logPrefix = "[amc.utils.localcache]"
+	// 快照格式版本, 格式不兼容时递增
+	snapshotVersion = 1
+)
+
+// Codec 表示快照的序列化方式
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec 使用 encoding/json 序列化, 是快照的默认 Codec。注意 key 为结构体时, 只有导出的
+// 字段会被序列化。
+type JSONCodec struct{}
+
+// Marshal 实现 Codec
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal 实现 Codec
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type snapshot[K comparable, V any] struct {
+	Version int                  `json:"version"`
+	Items   []snapshotItem[K, V] `json:"items"`
+}
+
+type snapshotItem[K comparable, V any] struct {
+	Key      K     `json:"k"`
+	Value    V     `json:"v"`
+	ExpireAt int64 `json:"e,omitempty"` // UnixNano 绝对时间, 使得恢复之后剩余的有效期不变
+}
+
+// Dump 将所有未过期的元素写入 w, 元素按照最近使用的时间排序
+func (c *cacheImpl[K, V]) Dump(w io.Writer) error {
+	now := time.Now().UnixNano()
+	snap := snapshot[K, V]{
+		Version: snapshotVersion,
+	}
+
+	c.lock.Lock()
+	snap.Items = make([]snapshotItem[K, V], 0, len(c.items))
+	// 从最久未使用的开始, 恢复时依次写入即可还原 LRU 顺序
+	for el := c.lru.Back(); el != nil; el = el.Prev() {
+		e, _ := el.Value.(*entry[K, V])
+		if e.expired(now) {
+			continue
+		}
+		snap.Items = append(snap.Items, snapshotItem[K, V]{
+			Key:      e.key,
+			Value:    e.value,
+			ExpireAt: e.expireAt,
+		})
+	}
+	c.lock.Unlock()
+
+	b, err := c.opts.snapshotCodec.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("marshal snapshot error: %w", err)
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Restore 从 r 中读取 Dump 写入的数据并写入缓存, 已经过期的元素会被忽略
+func (c *cacheImpl[K, V]) Restore(r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("read snapshot error: %w", err)
+	}
+	snap := snapshot[K, V]{}
+	if err := c.opts.snapshotCodec.Unmarshal(b, &snap); err != nil {
+		return fmt.Errorf("unmarshal snapshot error: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+
+	now := time.Now().UnixNano()
+	for _, item := range snap.Items {
+		switch {
+		case item.ExpireAt == 0:
+			c.SetWithExpire(item.Key, item.Value, 0)
+		case item.ExpireAt > now:
+			c.SetWithExpire(item.Key, item.Value, time.Duration(item.ExpireAt-now))
+		}
+	}
+	return nil
+}
+
+// restoreFromFile 在 New 时从 WithSnapshotFile 指定的文件恢复数据
+func (c *cacheImpl[K, V]) restoreFromFile() {
+	f, err := os.Open(c.opts.snapshotFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("%s open snapshot file '%s' error: '%v'", logPrefix, c.opts.snapshotFile, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	if err := c.Restore(f); err != nil {
+		log.Warnf("%s restore from '%s' error: '%v'", logPrefix, c.opts.snapshotFile, err)
+	}
+}
+
+// dumpToFile 在 Close 时将数据写入 WithSnapshotFile 指定的文件。先写临时文件再重命名, 避免
+// 进程中途退出时留下不完整的快照。
+func (c *cacheImpl[K, V]) dumpToFile() error {
+	path := c.opts.snapshotFile
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := c.Dump(tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
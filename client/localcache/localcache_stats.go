@@ -0,0 +1,124 @@
+package localcache
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/Andrew-M-C/trpc-go-utils/metrics"
+	"trpc.group/trpc-go/trpc-database/localcache"
+)
+
+// 通过 WithMetricsPrefix 上报统计数据的间隔
+const reportInterval = 10 * time.Second
+
+// Stats 表示缓存的统计数据, 除 Size 和 Cost 之外均为创建缓存以来的累计值
+type Stats struct {
+	Hits       uint64        `json:"hits"`
+	Misses     uint64        `json:"misses"`
+	Loads      uint64        `json:"loads"`
+	LoadErrors uint64        `json:"load_errors"`
+	LoadTime   time.Duration `json:"load_time"`   // 累计加载耗时
+	Deletes    uint64        `json:"deletes"`     // 主动删除及过期清理, 即 ItemDelete
+	LruDeletes uint64        `json:"lru_deletes"` // 超过容量被淘汰, 即 ItemLruDel
+	Rejects    uint64        `json:"rejects"`     // 被 TinyLFU 准入策略拒绝写入
+	Size       int           `json:"size"`
+	Cost       int64         `json:"cost"` // 当前所有元素的开销之和
+}
+
+// AverageLoadTime 返回平均加载耗时
+func (s Stats) AverageLoadTime() time.Duration {
+	if s.Loads == 0 {
+		return 0
+	}
+	return s.LoadTime / time.Duration(s.Loads)
+}
+
+type cacheStats struct {
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+	loads      atomic.Uint64
+	loadErrors atomic.Uint64
+	loadNanos  atomic.Int64
+	deletes    atomic.Uint64
+	lruDeletes atomic.Uint64
+	rejects    atomic.Uint64
+}
+
+func (s *cacheStats) hit(ok bool) {
+	if ok {
+		s.hits.Add(1)
+	} else {
+		s.misses.Add(1)
+	}
+}
+
+func (s *cacheStats) load(start time.Time, err error) {
+	s.loads.Add(1)
+	s.loadNanos.Add(int64(time.Since(start)))
+	if err != nil {
+		s.loadErrors.Add(1)
+	}
+}
+
+func (s *cacheStats) remove(flag localcache.ItemFlag) {
+	if flag == ItemLruDel {
+		s.lruDeletes.Add(1)
+	} else {
+		s.deletes.Add(1)
+	}
+}
+
+func (c *cacheImpl[K, V]) Stats() Stats {
+	c.lock.Lock()
+	size, cost := len(c.items), c.totalCost
+	c.lock.Unlock()
+
+	return Stats{
+		Hits:       c.stats.hits.Load(),
+		Misses:     c.stats.misses.Load(),
+		Loads:      c.stats.loads.Load(),
+		LoadErrors: c.stats.loadErrors.Load(),
+		LoadTime:   time.Duration(c.stats.loadNanos.Load()),
+		Deletes:    c.stats.deletes.Load(),
+		LruDeletes: c.stats.lruDeletes.Load(),
+		Rejects:    c.stats.rejects.Load(),
+		Size:       size,
+		Cost:       cost,
+	}
+}
+
+func (c *cacheImpl[K, V]) reportRoutine() {
+	tick := time.NewTicker(reportInterval)
+	defer tick.Stop()
+
+	prev := Stats{}
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-tick.C:
+			curr := c.Stats()
+			c.report(prev, curr)
+			prev = curr
+		}
+	}
+}
+
+// report 以计数器上报两次统计之间的增量, 以 gauge 上报当前大小和这段时间内的平均加载耗时
+func (c *cacheImpl[K, V]) report(prev, curr Stats) {
+	prefix := c.opts.metricsPrefix
+	metrics.IncrCounter(prefix+"hit", curr.Hits-prev.Hits)
+	metrics.IncrCounter(prefix+"miss", curr.Misses-prev.Misses)
+	metrics.IncrCounter(prefix+"load", curr.Loads-prev.Loads)
+	metrics.IncrCounter(prefix+"load.fail", curr.LoadErrors-prev.LoadErrors)
+	metrics.IncrCounter(prefix+"delete", curr.Deletes-prev.Deletes)
+	metrics.IncrCounter(prefix+"lruDelete", curr.LruDeletes-prev.LruDeletes)
+	metrics.IncrCounter(prefix+"reject", curr.Rejects-prev.Rejects)
+	metrics.SetGauge(prefix+"size", curr.Size)
+	metrics.SetGauge(prefix+"cost", curr.Cost)
+
+	if loads := curr.Loads - prev.Loads; loads > 0 {
+		avg := (curr.LoadTime - prev.LoadTime) / time.Duration(loads)
+		metrics.SetGauge(prefix+"load.avgMs", avg.Milliseconds())
+	}
+}
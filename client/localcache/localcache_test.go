@@ -1,8 +1,13 @@
 package localcache_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -16,9 +21,9 @@ var (
 	cv = convey.Convey
 	so = convey.So
 	eq = convey.ShouldEqual
-	gt = convey.ShouldBeGreaterThan
 	lt = convey.ShouldBeLessThan
 
+	isNil  = convey.ShouldBeNil
 	notNil = convey.ShouldNotBeNil
 )
 
@@ -30,6 +35,18 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 
+// waitFor 轮询直到 cond 返回 true, 超时返回 false
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return true
+}
+
 type testCache struct {
 	counter uint64
 }
@@ -80,16 +97,519 @@ func TestGetWithCustomLoad(t *testing.T) {
 
 		printf("加载次数: %d, 计数 %d", loadCount, c.counter)
 
-		// 理想情况下, 我们可能会期望同一个 key 只 load 一次。
-		// 但是实际情况下, 多个 load 并不是保证互斥的
-		actualSituation := true
-		if actualSituation {
-			so(loadCount, gt, 1)
-			so(c.counter, lt, concurrency*eachRepeat)
-		} else {
-			so(loadCount, eq, 1)
-			so(c.counter, eq, concurrency*eachRepeat)
+		so(loadCount, eq, 1)
+		so(c.counter, eq, concurrency*eachRepeat)
+	})
+
+	cv("load panic 时不阻塞等待方和后续调用", t, func() {
+		const key = "panic"
+		cache := localcache.New[*testCache]()
+		ctx := context.Background()
+
+		started := make(chan struct{})
+		unblock := make(chan struct{})
+		panicLoad := func(context.Context, string) (*testCache, error) {
+			close(started)
+			<-unblock
+			panic("oops")
+		}
+		normalLoad := func(context.Context, string) (*testCache, error) {
+			return &testCache{}, nil
+		}
+
+		ownerErr := make(chan error, 1)
+		go func() {
+			_, err := cache.GetWithCustomLoad(ctx, key, panicLoad, time.Hour)
+			ownerErr <- err
+		}()
+		<-started
+
+		waiterErr := make(chan error, 1)
+		go func() {
+			_, err := cache.GetWithCustomLoad(ctx, key, normalLoad, time.Hour)
+			waiterErr <- err
+		}()
+		// 等待方记录了未命中之后才会等待 owner 的加载结果
+		so(waitFor(func() bool {
+			return cache.(localcache.StatsGetter).Stats().Misses >= 2
+		}), eq, true)
+		close(unblock)
+
+		for i, ch := range []chan error{ownerErr, waiterErr} {
+			select {
+			case err := <-ch:
+				if i == 0 {
+					so(err, notNil) // panic 被转为错误
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("GetWithCustomLoad blocked after load panic")
+			}
+		}
+
+		c, err := cache.GetWithCustomLoad(ctx, key, normalLoad, time.Hour)
+		so(err, isNil)
+		so(c, notNil)
+	})
+}
+
+func TestMGetWithCustomLoad(t *testing.T) {
+	cv("测试多协程同时批量 load 重叠的 key", t, func() {
+		const concurrency = 1000
+
+		loadCount := map[string]*int64{}
+		for _, k := range []string{"a", "b", "c", "d"} {
+			loadCount[k] = new(int64)
+		}
+		release := make(chan struct{})
+		load := func(_ context.Context, keys []string) (map[string]*testCache, error) {
+			<-release // 所有协程都未命中之后才返回
+			res := make(map[string]*testCache, len(keys))
+			for _, k := range keys {
+				atomic.AddInt64(loadCount[k], 1)
+				res[k] = &testCache{}
+			}
+			return res, nil
+		}
+
+		cache := localcache.New[*testCache]()
+		keySets := [][]string{
+			{"a", "b"},
+			{"b", "c"},
+			{"c", "d", "a"},
+		}
+
+		expected := uint64(0)
+		wg := sync.WaitGroup{}
+		wg.Add(concurrency)
+		for i := 0; i < concurrency; i++ {
+			expected += uint64(len(keySets[i%len(keySets)]))
+			go func(keys []string) {
+				defer wg.Done()
+				m, err := cache.MGetWithCustomLoad(context.Background(), keys, load, time.Hour)
+				if err != nil {
+					panic(err)
+				}
+				for _, k := range keys {
+					atomic.AddUint64(&m[k].counter, 1)
+				}
+			}(keySets[i%len(keySets)])
 		}
+		so(waitFor(func() bool {
+			return cache.(localcache.StatsGetter).Stats().Misses == expected
+		}), eq, true)
+		close(release)
+		wg.Wait()
+
+		total := uint64(0)
+		for k, cnt := range loadCount {
+			so(*cnt, eq, 1)
+			c, exist := cache.Get(k)
+			so(exist, eq, true)
+			total += c.counter
+		}
+		so(total, eq, expected)
+	})
+}
+
+func TestExpiration(t *testing.T) {
+	cv("测试亚秒级过期时间", t, func() {
+		cache := localcache.New[int](localcache.WithExpiration(200 * time.Millisecond))
+		defer cache.Close()
+
+		cache.Set("a", 1)
+		cache.SetWithExpire("b", 2, 500*time.Millisecond)
+
+		time.Sleep(100 * time.Millisecond)
+		v, exist := cache.Get("a")
+		so(exist, eq, true)
+		so(v, eq, 1)
+
+		time.Sleep(200 * time.Millisecond)
+		_, exist = cache.Get("a")
+		so(exist, eq, false)
+		v, exist = cache.Get("b")
+		so(exist, eq, true)
+		so(v, eq, 2)
+
+		time.Sleep(300 * time.Millisecond)
+		_, exist = cache.Get("b")
+		so(exist, eq, false)
+	})
+
+	cv("测试过期后延迟删除", t, func() {
+		cache := localcache.New[int](localcache.WithDelay(time.Second))
+		defer cache.Close()
+
+		cache.SetWithExpire("a", 1, 100*time.Millisecond)
+		time.Sleep(200 * time.Millisecond)
+
+		_, exist := cache.Get("a")
+		so(exist, eq, false)
+		v, status := cache.GetWithStatus("a")
+		so(status, eq, localcache.CacheExpire)
+		so(v, eq, 1)
+	})
+}
+
+func TestRefresh(t *testing.T) {
+	cv("测试过期数据异步刷新", t, func() {
+		loadCount := int64(0)
+		release := make(chan struct{})
+		cache := localcache.New[int64](
+			localcache.WithExpiration(100*time.Millisecond),
+			localcache.WithStaleWhileRevalidate(time.Hour),
+			localcache.WithLoad(func(context.Context, string) (int64, error) {
+				cnt := atomic.AddInt64(&loadCount, 1)
+				if cnt > 1 {
+					<-release // 后台刷新在放行之前一直进行中
+				}
+				return cnt, nil
+			}),
+		)
+		defer cache.Close()
+
+		v, err := cache.GetWithLoad(context.Background(), "key")
+		so(err, isNil)
+		so(v, eq, 1)
+
+		so(waitFor(func() bool {
+			_, exist := cache.Get("key")
+			return !exist
+		}), eq, true)
+		for i := 0; i < 100; i++ {
+			start := time.Now()
+			v, err = cache.GetWithLoad(context.Background(), "key")
+			so(err, isNil)
+			so(v, eq, 1) // 返回过期的值, 不阻塞
+			so(time.Since(start), lt, 50*time.Millisecond)
+		}
+
+		close(release)
+		so(waitFor(func() bool {
+			v, err := cache.GetWithLoad(context.Background(), "key")
+			return err == nil && v == 2
+		}), eq, true)
+		so(atomic.LoadInt64(&loadCount), eq, 2)
+	})
+
+	cv("测试提前刷新", t, func() {
+		loadCount := int64(0)
+		refreshing := make(chan struct{})
+		release := make(chan struct{})
+		load := func(context.Context, string) (int64, error) {
+			cnt := atomic.AddInt64(&loadCount, 1)
+			if cnt > 1 {
+				close(refreshing)
+				<-release
+			}
+			return cnt, nil
+		}
+		cache := localcache.New[int64](localcache.WithRefreshAhead(0.5))
+		defer cache.Close()
+		ctx := context.Background()
+
+		v, err := cache.GetWithCustomLoad(ctx, "key", load, time.Second)
+		so(err, isNil)
+		so(v, eq, 1)
+
+		// 剩余有效期低于一半之后的访问触发后台刷新
+		so(waitFor(func() bool {
+			v, err := cache.GetWithCustomLoad(ctx, "key", load, time.Second)
+			so(err, isNil)
+			so(v, eq, 1)
+			select {
+			case <-refreshing:
+				return true
+			default:
+				return false
+			}
+		}), eq, true)
+
+		v, err = cache.GetWithCustomLoad(ctx, "key", load, time.Second)
+		so(err, isNil)
+		so(v, eq, 1) // 刷新期间依然返回旧值
 
+		close(release)
+		so(waitFor(func() bool {
+			v, exist := cache.Get("key")
+			return exist && v == 2
+		}), eq, true)
+		so(atomic.LoadInt64(&loadCount), eq, 2)
+	})
+
+	cv("后台刷新超时之后释放 key", t, func() {
+		cache := localcache.New[int64](
+			localcache.WithStaleWhileRevalidate(time.Hour),
+			localcache.WithLoadTimeout(50*time.Millisecond),
+		)
+		defer cache.Close()
+		ctx := context.Background()
+
+		started := make(chan struct{})
+		hang := make(chan struct{})
+		defer close(hang)
+		hungLoad := func(context.Context, string) (int64, error) {
+			close(started)
+			<-hang // 不理会 ctx 的超时
+			return 0, nil
+		}
+		load := func(context.Context, string) (int64, error) {
+			return 2, nil
+		}
+
+		cache.SetWithExpire("key", 1, time.Millisecond)
+		so(waitFor(func() bool {
+			_, exist := cache.Get("key")
+			return !exist
+		}), eq, true)
+
+		v, err := cache.GetWithCustomLoad(ctx, "key", hungLoad, time.Hour)
+		so(err, isNil)
+		so(v, eq, 1)
+		<-started
+
+		// 卡住的刷新超时之后, 后续的访问可以重新触发刷新
+		so(waitFor(func() bool {
+			v, err := cache.GetWithCustomLoad(ctx, "key", load, time.Hour)
+			return err == nil && v == 2
+		}), eq, true)
+	})
+}
+
+func TestStats(t *testing.T) {
+	cv("测试统计数据", t, func() {
+		cache := localcache.New[int](localcache.WithCapacity(2))
+		defer cache.Close()
+
+		load := func(_ context.Context, key string) (int, error) {
+			if key == "bad" {
+				return 0, errors.New("bad key")
+			}
+			return len(key), nil
+		}
+		_, _ = cache.GetWithCustomLoad(context.Background(), "a", load, time.Hour)
+		_, _ = cache.GetWithCustomLoad(context.Background(), "a", load, time.Hour)
+		_, err := cache.GetWithCustomLoad(context.Background(), "bad", load, time.Hour)
+		so(err, notNil)
+
+		cache.Set("b", 1)
+		cache.Set("c", 1) // 淘汰 a
+		cache.Del("b")
+
+		st := cache.(localcache.StatsGetter).Stats()
+		so(st.Hits, eq, 1)
+		so(st.Misses, eq, 2)
+		so(st.Loads, eq, 2)
+		so(st.LoadErrors, eq, 1)
+		so(st.LruDeletes, eq, 1)
+		so(st.Deletes, eq, 1)
+		so(st.Size, eq, 1)
+	})
+}
+
+func TestCost(t *testing.T) {
+	cv("测试按开销淘汰", t, func() {
+		cache := localcache.New[string](
+			localcache.WithCost(func(s string) int64 { return int64(len(s)) }),
+			localcache.WithMaxCost(100),
+		)
+		defer cache.Close()
+
+		small := strings.Repeat("s", 10)
+		big := strings.Repeat("b", 60)
+
+		hot := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+		for _, k := range hot {
+			so(cache.Set(k, small), eq, true)
+		}
+		for i := 0; i < 5; i++ {
+			for _, k := range hot {
+				_, exist := cache.Get(k)
+				so(exist, eq, true)
+			}
+		}
+
+		// 不常用的大元素不能挤掉常用的小元素
+		so(cache.Set("big", big), eq, false)
+		_, exist := cache.Get("big")
+		so(exist, eq, false)
+		for _, k := range hot {
+			_, exist := cache.Get(k)
+			so(exist, eq, true)
+		}
+		st := cache.(localcache.StatsGetter).Stats()
+		so(st.Cost, eq, 80)
+		so(st.Rejects, eq, 1)
+
+		// 超过最大开销的元素一定被拒绝
+		so(cache.Set("huge", strings.Repeat("h", 101)), eq, false)
+	})
+
+	cv("测试冷数据按 LRU 淘汰", t, func() {
+		cache := localcache.New[string](
+			localcache.WithCost(func(s string) int64 { return int64(len(s)) }),
+			localcache.WithMaxCost(100),
+		)
+		defer cache.Close()
+
+		for _, k := range []string{"a", "b", "c", "d", "e"} {
+			so(cache.Set(k, strings.Repeat("s", 20)), eq, true)
+		}
+		so(cache.Set("big", strings.Repeat("b", 60)), eq, true)
+
+		st := cache.(localcache.StatsGetter).Stats()
+		so(st.Cost, eq, 100)
+		so(st.LruDeletes, eq, 3)
+		_, exist := cache.Get("a")
+		so(exist, eq, false)
+		_, exist = cache.Get("e")
+		so(exist, eq, true)
+	})
+}
+
+func TestCache2(t *testing.T) {
+	cv("测试非字符串 key", t, func() {
+		type userKey struct {
+			appID  int
+			userID int64
+		}
+
+		deleted := []userKey{}
+		cache := localcache.New2[userKey, string](
+			localcache.WithLoad2(func(_ context.Context, k userKey) (string, error) {
+				return fmt.Sprintf("%d-%d", k.appID, k.userID), nil
+			}),
+			localcache.WithOnDel2(func(i localcache.Item2[userKey, string]) {
+				deleted = append(deleted, i.Key)
+			}),
+		)
+		defer cache.Close()
+
+		k := userKey{appID: 1, userID: 10086}
+		v, err := cache.GetWithLoad(context.Background(), k)
+		so(err, isNil)
+		so(v, eq, "1-10086")
+
+		v, exist := cache.Get(k)
+		so(exist, eq, true)
+		so(v, eq, "1-10086")
+
+		cache.Del(k)
+		so(len(deleted), eq, 1)
+		so(deleted[0], eq, k)
+	})
+
+	cv("测试整数 key 批量加载", t, func() {
+		cache := localcache.New2[int64, int64](localcache.WithMaxCost(100))
+		defer cache.Close()
+
+		m, err := cache.MGetWithCustomLoad(
+			context.Background(), []int64{1, 2, 3},
+			func(_ context.Context, keys []int64) (map[int64]int64, error) {
+				res := make(map[int64]int64, len(keys))
+				for _, k := range keys {
+					res[k] = k * 10
+				}
+				return res, nil
+			},
+			time.Hour,
+		)
+		so(err, isNil)
+		so(len(m), eq, 3)
+		so(m[2], eq, 20)
+		so(cache.Len(), eq, 3)
+	})
+
+	cv("自定义 key 哈希函数, 每次访问只计一次频率", t, func() {
+		type userKey struct {
+			appID  int
+			userID int64
+		}
+		hashes := map[userKey]int{}
+		cache := localcache.New2[userKey, string](
+			localcache.WithMaxCost(1000),
+			localcache.WithRefreshAhead(0.5),
+			localcache.WithKeyHasher2(func(k userKey) uint64 {
+				hashes[k]++
+				return uint64(k.appID)<<32 ^ uint64(k.userID)
+			}),
+		)
+		defer cache.Close()
+		ctx := context.Background()
+		load := func(_ context.Context, k userKey) (string, error) {
+			return fmt.Sprint(k.userID), nil
+		}
+
+		k1, k2 := userKey{1, 1}, userKey{1, 2}
+		_, err := cache.GetWithCustomLoad(ctx, k1, load, time.Hour)
+		so(err, isNil)
+		so(hashes[k1], eq, 1)
+
+		_, err = cache.GetWithCustomLoad(ctx, k1, load, time.Hour)
+		so(err, isNil)
+		so(hashes[k1], eq, 2)
+
+		_, err = cache.MGetWithCustomLoad(
+			ctx, []userKey{k1, k2},
+			func(_ context.Context, keys []userKey) (map[userKey]string, error) {
+				res := map[userKey]string{}
+				for _, k := range keys {
+					res[k], _ = load(ctx, k)
+				}
+				return res, nil
+			},
+			time.Hour,
+		)
+		so(err, isNil)
+		so(hashes[k1], eq, 3)
+		so(hashes[k2], eq, 1)
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	cv("测试 Dump / Restore", t, func() {
+		cache := localcache.New[int]()
+		cache.Set("forever", 1)
+		cache.SetWithExpire("short", 2, 200*time.Millisecond)
+		cache.SetWithExpire("expired", 3, time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+
+		buff := bytes.Buffer{}
+		err := cache.(localcache.Snapshotter).Dump(&buff)
+		so(err, isNil)
+		cache.Close()
+
+		restored := localcache.New[int]()
+		defer restored.Close()
+		err = restored.(localcache.Snapshotter).Restore(&buff)
+		so(err, isNil)
+		so(restored.Len(), eq, 2)
+
+		v, exist := restored.Get("forever")
+		so(exist, eq, true)
+		so(v, eq, 1)
+		v, exist = restored.Get("short")
+		so(exist, eq, true)
+		so(v, eq, 2)
+
+		// 剩余的有效期保持不变
+		time.Sleep(200 * time.Millisecond)
+		_, exist = restored.Get("short")
+		so(exist, eq, false)
+	})
+
+	cv("测试快照文件", t, func() {
+		path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+		cache := localcache.New[string](localcache.WithSnapshotFile(path))
+		so(cache.Len(), eq, 0)
+		cache.Set("key", "value")
+		cache.Close()
+
+		cache = localcache.New[string](localcache.WithSnapshotFile(path))
+		defer cache.Close()
+		v, exist := cache.Get("key")
+		so(exist, eq, true)
+		so(v, eq, "value")
 	})
 }
@@ -0,0 +1,158 @@
+package localcache
+
+import (
+	"fmt"
+	"hash/maphash"
+)
+
+const (
+	// count-min sketch 的行数
+	sketchDepth = 4
+	// 计数器上限, 超过后不再增加
+	sketchMaxCount = 15
+	// sketch 每行的宽度范围
+	sketchMinWidth = 1 << 10
+	sketchMaxWidth = 1 << 18
+)
+
+// sketch 是 TinyLFU 使用的 count-min sketch, 以很小的内存代价估算 key 的近期访问频率。
+// 累计增加次数达到宽度的 10 倍时, 所有计数减半, 使得频率反映的是近期的访问情况。
+//
+// sketch 不是并发安全的, 需要在 cacheImpl.lock 下使用。
+type sketch struct {
+	seed  maphash.Seed
+	rows  [sketchDepth][]uint8
+	mask  uint64
+	added int
+	reset int
+}
+
+func newSketch(capacity int) *sketch {
+	width := sketchMinWidth
+	for width < capacity && width < sketchMaxWidth {
+		width <<= 1
+	}
+	s := &sketch{
+		seed:  maphash.MakeSeed(),
+		mask:  uint64(width - 1),
+		reset: width * 10,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// sketchHash 计算 key 的哈希值。常用的 key 类型直接计算, 其他类型退化为格式化成字符串, 每次调用
+// 都会分配内存, 因此结构体等类型的 key 请通过 WithKeyHasher2 指定哈希函数。
+func sketchHash[K comparable](s *sketch, key K) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		return maphash.String(s.seed, k)
+	case int:
+		return uint64(k)
+	case int8:
+		return uint64(k)
+	case int16:
+		return uint64(k)
+	case int32:
+		return uint64(k)
+	case int64:
+		return uint64(k)
+	case uint:
+		return uint64(k)
+	case uint8:
+		return uint64(k)
+	case uint16:
+		return uint64(k)
+	case uint32:
+		return uint64(k)
+	case uint64:
+		return k
+	case uintptr:
+		return uint64(k)
+	default:
+		return maphash.String(s.seed, fmt.Sprint(k))
+	}
+}
+
+// index 返回 h 在第 row 行中的位置
+func (s *sketch) index(h uint64, row int) uint64 {
+	h += uint64(row) * 0x9e3779b97f4a7c15
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	return h & s.mask
+}
+
+// increment 记录一次访问, h 为 sketchHash 的返回值
+func (s *sketch) increment(h uint64) {
+	for i := range s.rows {
+		idx := s.index(h, i)
+		if s.rows[i][idx] < sketchMaxCount {
+			s.rows[i][idx]++
+		}
+	}
+
+	s.added++
+	if s.added >= s.reset {
+		s.halve()
+	}
+}
+
+// estimate 估算访问频率, h 为 sketchHash 的返回值
+func (s *sketch) estimate(h uint64) uint8 {
+	res := uint8(sketchMaxCount)
+	for i := range s.rows {
+		res = min(res, s.rows[i][s.index(h, i)])
+	}
+	return res
+}
+
+func (s *sketch) halve() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.added /= 2
+}
+
+// admitLocked 判断在开启了 WithMaxCost 时, 是否允许写入一个新的元素。需要淘汰元素时, 从 LRU
+// 链表尾部开始, 只要新元素的访问频率低于任意一个待淘汰元素, 就拒绝写入, 避免不常用的大元素
+// 挤掉常用的小元素。
+func (c *cacheImpl[K, V]) admitLocked(key K, cost int64) bool {
+	if cost > c.opts.maxCost {
+		return false
+	}
+	need := c.totalCost + cost - c.opts.maxCost
+	if need <= 0 {
+		return true
+	}
+
+	freq := c.sketch.estimate(c.keyHash(key))
+	for el := c.lru.Back(); el != nil && need > 0; el = el.Prev() {
+		victim, _ := el.Value.(*entry[K, V])
+		if freq < c.sketch.estimate(c.keyHash(victim.key)) {
+			return false
+		}
+		need -= victim.cost
+	}
+	return true
+}
+
+// keyHash 计算 key 在 sketch 中的哈希值, 优先使用 WithKeyHasher2 指定的函数
+func (c *cacheImpl[K, V]) keyHash(key K) uint64 {
+	if c.hasher != nil {
+		return c.hasher(key)
+	}
+	return sketchHash(c.sketch, key)
+}
+
+// recordAccessLocked 在开启了 WithMaxCost 时将一次访问计入 key 的访问频率。每次访问只能调用一次,
+// 否则会高估访问频率。
+func (c *cacheImpl[K, V]) recordAccessLocked(key K) {
+	if c.sketch != nil {
+		c.sketch.increment(c.keyHash(key))
+	}
+}
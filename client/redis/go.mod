@@ -1,22 +1,35 @@
 module github.com/Andrew-M-C/trpc-go-utils/client/redis
 
-go 1.23.0
-
-toolchain go1.23.5
+go 1.23.5
 
 require (
-	github.com/Andrew-M-C/trpc-go-utils/client/buffer v0.0.0-20250116064610-a34214869a16
+	github.com/Andrew-M-C/trpc-go-utils/client/buffer v0.0.0-20261018070043-69c7b768dace
+	github.com/Andrew-M-C/trpc-go-utils/client/localcache v0.0.0-20261018072602-a5612cc9187d
+	github.com/Andrew-M-C/trpc-go-utils/metrics v0.0.0-20250116064400-067a5f44757f
+	github.com/Andrew-M-C/trpc-go-utils/plugin v0.0.0-20250116072106-212bb22d96bb
+	github.com/alicebob/miniredis/v2 v2.34.0
 	github.com/redis/go-redis/v9 v9.7.0
+	github.com/smartystreets/goconvey v1.8.1
+	golang.org/x/sync v0.13.0
 	trpc.group/trpc-go/trpc-database/goredis v1.0.0
 	trpc.group/trpc-go/trpc-go v1.0.3
 )
 
 require (
+	github.com/Andrew-M-C/go.jsonvalue v1.4.2 // indirect
+	github.com/Andrew-M-C/go.util/log v0.0.0-20251111084840-655d831cc1cf // indirect
+	github.com/Andrew-M-C/go.util/runtime v0.0.0-20251120101424-fd2377cf6964 // indirect
 	github.com/Andrew-M-C/go.util/sync v0.0.0-20250116061329-8e3db2afac06 // indirect
-	github.com/Andrew-M-C/trpc-go-utils/metrics v0.0.0-20250116064400-067a5f44757f // indirect
+	github.com/Andrew-M-C/go.util/unsafe v0.0.0-20250116061329-8e3db2afac06 // indirect
+	github.com/Andrew-M-C/trpc-go-utils/concurrent v0.0.0-20261018052536-8962249bc152 // indirect
+	github.com/Andrew-M-C/trpc-go-utils/log v0.0.0-20250918061229-7193c133ae97 // indirect
+	github.com/Andrew-M-C/trpc-go-utils/recovery v0.0.0-20250918061229-7193c133ae97 // indirect
 	github.com/BurntSushi/toml v1.5.0 // indirect
+	github.com/RussellLuo/timingwheel v0.0.0-20191022104228-f534fd34a762 // indirect
+	github.com/alicebob/gopher-json v0.0.0-20230218143504-906a9b012302 // indirect
 	github.com/andybalholm/brotli v1.1.1 // indirect
 	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash v1.1.0 // indirect
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/dlclark/regexp2 v1.7.0 // indirect
@@ -26,9 +39,11 @@ require (
 	github.com/golang/snappy v1.0.0 // indirect
 	github.com/google/flatbuffers v25.2.10+incompatible // indirect
 	github.com/google/uuid v1.6.0 // indirect
+	github.com/gopherjs/gopherjs v1.17.2 // indirect
 	github.com/hashicorp/errwrap v1.1.0 // indirect
 	github.com/hashicorp/go-multierror v1.1.1 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/jtolds/gls v4.20.0+incompatible // indirect
 	github.com/klauspost/compress v1.18.0 // indirect
 	github.com/lestrrat-go/strftime v1.1.0 // indirect
 	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
@@ -47,17 +62,19 @@ require (
 	github.com/prometheus/common v0.32.1 // indirect
 	github.com/prometheus/procfs v0.7.3 // indirect
 	github.com/rogpeppe/go-internal v1.11.0 // indirect
+	github.com/shopspring/decimal v1.4.0 // indirect
+	github.com/smarty/assertions v1.15.0 // indirect
 	github.com/spaolacci/murmur3 v1.1.0 // indirect
 	github.com/spf13/cast v1.7.1 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasthttp v1.61.0 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
 	go.uber.org/atomic v1.11.0 // indirect
 	go.uber.org/automaxprocs v1.6.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/zap v1.27.0 // indirect
 	golang.org/x/exp v0.0.0-20250106191152-7588d65b2ba8 // indirect
 	golang.org/x/net v0.39.0 // indirect
-	golang.org/x/sync v0.13.0 // indirect
 	golang.org/x/sys v0.32.0 // indirect
 	golang.org/x/text v0.24.0 // indirect
 	google.golang.org/genproto v0.0.0-20220617124728-180714bec0ad // indirect
@@ -67,5 +84,6 @@ require (
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	trpc.group/trpc-go/tnet v1.0.1 // indirect
+	trpc.group/trpc-go/trpc-database/localcache v1.0.0 // indirect
 	trpc.group/trpc/trpc-protocol/pb/go/trpc v1.0.0 // indirect
 )
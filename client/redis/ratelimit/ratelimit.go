@@ -0,0 +1,161 @@
+// Package ratelimit 提供基于 Redis 的分布式限流工具, 支持令牌桶和滑动窗口两种算法
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/Andrew-M-C/trpc-go-utils/client/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Limiter 表示一个限流器
+type Limiter interface {
+	// Allow 消耗 key 的一个配额, 返回本次请求是否被允许
+	Allow(ctx context.Context, key string) (bool, error)
+}
+
+var (
+	// 令牌桶。使用 Redis 服务器时间, 避免各实例之间时钟不一致
+	tokenBucketScript = goredis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local data = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tokens, "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return allowed
+`)
+
+	// 滑动窗口日志, 以有序集合记录窗口内每次请求的时间
+	slidingWindowScript = goredis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+if redis.call("ZCARD", KEYS[1]) >= limit then
+	return 0
+end
+redis.call("ZADD", KEYS[1], now, ARGV[3])
+redis.call("PEXPIRE", KEYS[1], window)
+return 1
+`)
+)
+
+// TokenBucket 令牌桶限流器: 以每秒 rate 个的速度补充令牌, 最多积累 burst 个
+type TokenBucket struct {
+	getter redis.Getter
+	rate   float64
+	burst  int
+	opts   options
+}
+
+// NewTokenBucket 新建令牌桶限流器, getter 一般为 redis.ClientGetter 的返回值
+func NewTokenBucket(getter redis.Getter, rate float64, burst int, opts ...Option) (*TokenBucket, error) {
+	if rate <= 0 {
+		return nil, fmt.Errorf("invalid token bucket rate %v", rate)
+	}
+	if burst <= 0 {
+		return nil, fmt.Errorf("invalid token bucket burst %d", burst)
+	}
+	return &TokenBucket{
+		getter: getter,
+		rate:   rate,
+		burst:  burst,
+		opts:   mergeOptions(opts),
+	}, nil
+}
+
+// Allow 实现 Limiter
+func (l *TokenBucket) Allow(ctx context.Context, key string) (bool, error) {
+	cli, err := l.getter(ctx)
+	if err != nil {
+		return false, err
+	}
+	res, err := tokenBucketScript.Run(
+		ctx, cli, []string{l.opts.keyPrefix + key}, l.rate, l.burst,
+	).Int64()
+	if err != nil {
+		count("redis.fail")
+		return false, fmt.Errorf("run token bucket for key '%s' error: %w", key, err)
+	}
+	return allowed(res), nil
+}
+
+// SlidingWindow 滑动窗口限流器: 任意 window 时长内最多允许 limit 次请求
+type SlidingWindow struct {
+	getter redis.Getter
+	limit  int
+	window time.Duration
+	opts   options
+}
+
+// NewSlidingWindow 新建滑动窗口限流器, getter 一般为 redis.ClientGetter 的返回值。
+// 窗口内的每次请求都会在 Redis 中记录一条数据, 因此不适合 limit 特别大的场景。
+func NewSlidingWindow(getter redis.Getter, limit int, window time.Duration, opts ...Option) (*SlidingWindow, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid sliding window limit %d", limit)
+	}
+	if window < time.Millisecond {
+		return nil, fmt.Errorf("invalid sliding window %v", window)
+	}
+	return &SlidingWindow{
+		getter: getter,
+		limit:  limit,
+		window: window,
+		opts:   mergeOptions(opts),
+	}, nil
+}
+
+// Allow 实现 Limiter
+func (l *SlidingWindow) Allow(ctx context.Context, key string) (bool, error) {
+	cli, err := l.getter(ctx)
+	if err != nil {
+		return false, err
+	}
+	res, err := slidingWindowScript.Run(
+		ctx, cli, []string{l.opts.keyPrefix + key}, l.limit, l.window.Milliseconds(), newMember(),
+	).Int64()
+	if err != nil {
+		count("redis.fail")
+		return false, fmt.Errorf("run sliding window for key '%s' error: %w", key, err)
+	}
+	return allowed(res), nil
+}
+
+func allowed(res int64) bool {
+	if res == 1 {
+		count("allow")
+		return true
+	}
+	count("reject")
+	return false
+}
+
+// newMember 生成有序集合中唯一的成员, 避免同一毫秒内的请求互相覆盖
+func newMember() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
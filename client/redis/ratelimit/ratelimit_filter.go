@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Andrew-M-C/trpc-go-utils/client/redis"
+	"github.com/Andrew-M-C/trpc-go-utils/plugin"
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/errs"
+	"trpc.group/trpc-go/trpc-go/filter"
+	"trpc.group/trpc-go/trpc-go/log"
+)
+
+const (
+	// FilterName 限流 filter 名称
+	FilterName = "redis_ratelimit"
+	// PluginType 限流 filter 的 plugin 配置类型
+	PluginType = "filter"
+	// PluginName 限流 filter 的 plugin 配置名
+	PluginName = "redis_ratelimit"
+)
+
+const (
+	// AlgorithmTokenBucket 令牌桶算法, 使用 rate 和 burst 配置
+	AlgorithmTokenBucket = "token_bucket"
+	// AlgorithmSlidingWindow 滑动窗口算法, 使用 limit 和 window 配置
+	AlgorithmSlidingWindow = "sliding_window"
+)
+
+const filterLogPrefix = "[amc.utils.redis.ratelimit]"
+
+// FilterConfig 表示限流 filter 的配置, 示例:
+//
+//	plugins:
+//	  filter:
+//	    redis_ratelimit:
+//	      redis: trpc.redis.ratelimit
+//	      key_prefix: "myapp:ratelimit:"
+//	      default:
+//	        algorithm: token_bucket
+//	        rate: 100
+//	        burst: 200
+//	      methods:
+//	        /trpc.app.server.Greeter/SayHello:
+//	          algorithm: sliding_window
+//	          limit: 10
+//	          window: 1s
+type FilterConfig struct {
+	// Redis 客户端名称, 对应 client 配置中的 service name
+	Redis     string `yaml:"redis"`
+	KeyPrefix string `yaml:"key_prefix"`
+	// Default 未在 Methods 中配置的方法使用的规则, 为空则不限流
+	Default *RuleConfig           `yaml:"default"`
+	Methods map[string]RuleConfig `yaml:"methods"`
+}
+
+// RuleConfig 表示单个方法的限流规则
+type RuleConfig struct {
+	Algorithm string        `yaml:"algorithm"`
+	Rate      float64       `yaml:"rate"`
+	Burst     int           `yaml:"burst"`
+	Limit     int           `yaml:"limit"`
+	Window    time.Duration `yaml:"window"`
+}
+
+// RegisterFilter 注册 redis_ratelimit server filter。限流以 RPC 方法名为 key 在所有实例之间
+// 共享配额, 超限时返回 RetServerThrottled 错误码; Redis 异常时放行请求。
+//
+// 必须在 trpc.NewServer 之前调用
+func RegisterFilter() {
+	internal.filterInitOnce.Do(func() {
+		filter.Register(FilterName, serverFilter, nil)
+		plugin.Register(PluginType, PluginName, setupFilter)
+	})
+}
+
+func setupFilter(conf *FilterConfig) error {
+	if conf.Redis == "" {
+		return fmt.Errorf("missing redis client name for filter %s", FilterName)
+	}
+	getter := redis.ClientGetter(conf.Redis)
+
+	var opts []Option
+	if conf.KeyPrefix != "" {
+		opts = append(opts, WithKeyPrefix(conf.KeyPrefix))
+	}
+
+	rules := &filterRules{
+		methods: make(map[string]Limiter, len(conf.Methods)),
+	}
+	if conf.Default != nil {
+		l, err := newLimiter(getter, *conf.Default, opts)
+		if err != nil {
+			return fmt.Errorf("invalid default rule: %w", err)
+		}
+		rules.def = l
+	}
+	for method, rule := range conf.Methods {
+		l, err := newLimiter(getter, rule, opts)
+		if err != nil {
+			return fmt.Errorf("invalid rule for method '%s': %w", method, err)
+		}
+		rules.methods[method] = l
+	}
+
+	internal.rules.Store(rules)
+	return nil
+}
+
+func newLimiter(getter redis.Getter, rule RuleConfig, opts []Option) (Limiter, error) {
+	switch rule.Algorithm {
+	case AlgorithmTokenBucket, "":
+		return NewTokenBucket(getter, rule.Rate, rule.Burst, opts...)
+	case AlgorithmSlidingWindow:
+		return NewSlidingWindow(getter, rule.Limit, rule.Window, opts...)
+	default:
+		return nil, fmt.Errorf("unknown algorithm '%s'", rule.Algorithm)
+	}
+}
+
+func serverFilter(ctx context.Context, req any, next filter.ServerHandleFunc) (any, error) {
+	rules := internal.rules.Load()
+	if rules == nil {
+		return next(ctx, req)
+	}
+
+	method := codec.Message(ctx).ServerRPCName()
+	l, exist := rules.methods[method]
+	if !exist {
+		l = rules.def
+	}
+	if l == nil {
+		return next(ctx, req)
+	}
+
+	ok, err := l.Allow(ctx, method)
+	if err != nil {
+		log.WarnContextf(ctx, "%s check method '%s' error, pass: '%v'", filterLogPrefix, method, err)
+		return next(ctx, req)
+	}
+	if !ok {
+		return nil, errs.New(errs.RetServerThrottled, fmt.Sprintf("method '%s' is throttled", method))
+	}
+	return next(ctx, req)
+}
@@ -0,0 +1,25 @@
+package ratelimit
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/Andrew-M-C/trpc-go-utils/metrics"
+)
+
+const metricsPrefix = "amc.utils.redis.ratelimit."
+
+var internal = struct {
+	filterInitOnce sync.Once
+	rules          atomic.Pointer[filterRules]
+}{}
+
+// filterRules 表示从 plugin 配置中构建出的各方法的限流器
+type filterRules struct {
+	def     Limiter
+	methods map[string]Limiter
+}
+
+func count(name string) {
+	metrics.IncrCounter(metricsPrefix+name, 1)
+}
@@ -0,0 +1,30 @@
+package ratelimit
+
+// 默认的 Redis key 前缀
+const defaultKeyPrefix = "amc:ratelimit:"
+
+// Option 表示限流器的额外参数
+type Option func(*options)
+
+type options struct {
+	keyPrefix string
+}
+
+func mergeOptions(opts []Option) options {
+	opt := options{
+		keyPrefix: defaultKeyPrefix,
+	}
+	for _, o := range opts {
+		if o != nil {
+			o(&opt)
+		}
+	}
+	return opt
+}
+
+// WithKeyPrefix 指定 Redis key 的前缀, 默认为 "amc:ratelimit:"
+func WithKeyPrefix(prefix string) Option {
+	return func(o *options) {
+		o.keyPrefix = prefix
+	}
+}
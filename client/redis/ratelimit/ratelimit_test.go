@@ -0,0 +1,113 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	amcredis "github.com/Andrew-M-C/trpc-go-utils/client/redis"
+	"github.com/Andrew-M-C/trpc-go-utils/client/redis/ratelimit"
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/redis/go-redis/v9"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+var (
+	cv = convey.Convey
+	so = convey.So
+	eq = convey.ShouldEqual
+
+	isNil  = convey.ShouldBeNil
+	notNil = convey.ShouldNotBeNil
+)
+
+// newMiniredis 启动一个时间固定的 miniredis, 限流脚本通过 TIME 命令读取这个时间
+func newMiniredis(t *testing.T) (*miniredis.Miniredis, amcredis.Getter) {
+	mr := miniredis.RunT(t)
+	mr.SetTime(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	cli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = cli.Close() })
+	return mr, func(context.Context) (redis.UniversalClient, error) {
+		return cli, nil
+	}
+}
+
+// allowN 连续调用 n 次 Allow, 返回被允许的次数
+func allowN(l ratelimit.Limiter, key string, n int) int {
+	cnt := 0
+	for i := 0; i < n; i++ {
+		ok, err := l.Allow(context.Background(), key)
+		so(err, isNil)
+		if ok {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+func TestTokenBucket(t *testing.T) {
+	cv("令牌桶", t, func() {
+		mr, getter := newMiniredis(t)
+		start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		l, err := ratelimit.NewTokenBucket(getter, 2, 3)
+		so(err, isNil)
+
+		// 初始时可以消耗 burst 个令牌
+		so(allowN(l, "k", 4), eq, 3)
+
+		// 每秒补充 rate 个, 不足 1 个时拒绝
+		mr.SetTime(start.Add(499 * time.Millisecond))
+		so(allowN(l, "k", 1), eq, 0)
+		mr.SetTime(start.Add(999 * time.Millisecond))
+		so(allowN(l, "k", 2), eq, 1)
+
+		// 最多积累 burst 个
+		mr.SetTime(start.Add(time.Hour))
+		so(allowN(l, "k", 5), eq, 3)
+
+		// 不同的 key 互不影响
+		so(allowN(l, "other", 3), eq, 3)
+	})
+
+	cv("非法参数", t, func() {
+		_, getter := newMiniredis(t)
+		_, err := ratelimit.NewTokenBucket(getter, 0, 1)
+		so(err, notNil)
+		_, err = ratelimit.NewTokenBucket(getter, 1, 0)
+		so(err, notNil)
+	})
+}
+
+func TestSlidingWindow(t *testing.T) {
+	cv("滑动窗口", t, func() {
+		mr, getter := newMiniredis(t)
+		start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		l, err := ratelimit.NewSlidingWindow(getter, 2, time.Second)
+		so(err, isNil)
+
+		so(allowN(l, "k", 3), eq, 2)
+
+		mr.SetTime(start.Add(500 * time.Millisecond))
+		so(allowN(l, "k", 1), eq, 0)
+
+		// 窗口边界: 距离第一批请求恰好 window 时长时, 第一批请求移出窗口
+		mr.SetTime(start.Add(999 * time.Millisecond))
+		so(allowN(l, "k", 1), eq, 0)
+		mr.SetTime(start.Add(time.Second))
+		so(allowN(l, "k", 3), eq, 2)
+
+		// 窗口滑过之后恢复配额
+		mr.SetTime(start.Add(2 * time.Second))
+		so(allowN(l, "k", 1), eq, 1)
+	})
+
+	cv("非法参数", t, func() {
+		_, getter := newMiniredis(t)
+		_, err := ratelimit.NewSlidingWindow(getter, 0, time.Second)
+		so(err, notNil)
+		_, err = ratelimit.NewSlidingWindow(getter, 1, time.Microsecond)
+		so(err, notNil)
+	})
+}
@@ -3,25 +3,80 @@ package redis
 
 import (
 	"context"
+	"time"
 
 	"github.com/Andrew-M-C/trpc-go-utils/client/buffer"
 	redis "github.com/redis/go-redis/v9"
 	"trpc.group/trpc-go/trpc-database/goredis"
 	"trpc.group/trpc-go/trpc-go/client"
+	"trpc.group/trpc-go/trpc-go/server"
 )
 
+// Getter 表示动态获取 Redis 客户端的函数, 即 ClientGetter 的返回值
+type Getter = func(context.Context) (redis.UniversalClient, error)
+
 // ClientGetter 返回动态获取 Redis 客户端的函数
-func ClientGetter(
-	name string, opts ...client.Option,
-) func(context.Context) (redis.UniversalClient, error) {
+func ClientGetter(name string, opts ...client.Option) Getter {
 	return func(ctx context.Context) (redis.UniversalClient, error) {
 		return buff.GetClient(name, opts)
 	}
 }
 
-var buff *buffer.ClientBuffer[redis.UniversalClient] = buffer.NewClientBuffer(
-	"amc.utils.redis.", newRedis, closeRedis,
-)
+// Acquirer 表示占用 Redis 客户端的函数, 即 ClientAcquirer 的返回值。使用完毕后必须调用 release
+// 归还 (重复调用是安全的)。
+type Acquirer = func(context.Context) (cli redis.UniversalClient, release func(), err error)
+
+// ClientAcquirer 返回占用 Redis 客户端的函数。与 ClientGetter 不同, 被占用的客户端在归还之前
+// 不会因为配置变化而被关闭, 适用于长时间的 pipeline、订阅或者分布式锁等场景。
+func ClientAcquirer(name string, opts ...client.Option) Acquirer {
+	return func(ctx context.Context) (redis.UniversalClient, func(), error) {
+		return buff.Acquire(name, opts)
+	}
+}
+
+// Do 占用 name 对应的 Redis 客户端并执行 fn, fn 返回后自动归还
+func Do(
+	ctx context.Context, name string,
+	fn func(context.Context, redis.UniversalClient) error, opts ...client.Option,
+) error {
+	return buff.Do(ctx, name, opts, fn)
+}
+
+var buff *buffer.ClientBuffer[redis.UniversalClient] = newBuffer()
+
+// SetBufferOptions 指定 Redis 客户端缓存池的参数, 比如 buffer.WithBackgroundRefresh、
+// buffer.WithHealthCheck 等。默认在配置变化时使用 PING 检查新的客户端, 检查失败时继续使用旧的
+// 客户端, 可以通过 buffer.WithHealthCheck 替换。
+//
+// SetBufferOptions 不是并发安全的, 必须在第一次获取客户端之前调用, 比如在 main 函数中创建
+// tRPC server 之后。之前创建的客户端会被关闭。
+func SetBufferOptions(opts ...buffer.Option[redis.UniversalClient]) {
+	prev := buff
+	buff = newBuffer(opts...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_ = prev.Close(ctx)
+}
+
+func newBuffer(opts ...buffer.Option[redis.UniversalClient]) *buffer.ClientBuffer[redis.UniversalClient] {
+	opts = append([]buffer.Option[redis.UniversalClient]{buffer.WithHealthCheck(pingRedis)}, opts...)
+	return buffer.NewClientBuffer(metricsPrefix, newRedis, closeRedis, opts...)
+}
+
+// Close 关闭所有通过 ClientGetter 创建的 Redis 客户端, 在进程退出时调用
+func Close(ctx context.Context) error {
+	return buff.Close(ctx)
+}
+
+// RegisterOnShutdown 在 tRPC server 退出时关闭所有 Redis 客户端, 最多等待 timeout 时长
+func RegisterOnShutdown(s *server.Server, timeout time.Duration) {
+	buff.RegisterOnShutdown(s, timeout)
+}
+
+func pingRedis(ctx context.Context, cli redis.UniversalClient) error {
+	return cli.Ping(ctx).Err()
+}
 
 func closeRedis(cli redis.UniversalClient) error {
 	return cli.Close()
@@ -0,0 +1,218 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+	"trpc.group/trpc-go/trpc-go/log"
+)
+
+const (
+	// ErrNotFound 表示数据不存在。LoadFunc 在数据源中找不到数据时应当返回 (或 wrap) 这个错误,
+	// 开启了 WithNegativeTTL 时这个结果也会被缓存。
+	ErrNotFound = E("amc redis cache: not found")
+)
+
+const cacheLogPrefix = "[amc.utils.redis.cache]"
+
+// 缓存值的第一个字节, 用于区分正常值与 "不存在" 的标记
+const (
+	cacheFlagValue    byte = 'v'
+	cacheFlagNotFound byte = 'n'
+)
+
+// LoadFunc 在缓存未命中时从数据源加载数据
+type LoadFunc[T any] func(ctx context.Context, key string) (T, error)
+
+// Cache 基于 Redis 的 cache-aside 缓存工具: 先读 Redis, 未命中时调用 LoadFunc 加载并写回
+// Redis。同一个 key 的并发加载会被合并为一次。
+type Cache[T any] struct {
+	getter Getter
+	opts   cacheOptions
+	group  singleflight.Group
+}
+
+// NewCache 新建一个 Redis 缓存, getter 一般为 ClientGetter 的返回值
+func NewCache[T any](getter Getter, opts ...CacheOption) *Cache[T] {
+	return &Cache[T]{
+		getter: getter,
+		opts:   mergeCacheOptions(opts),
+	}
+}
+
+// Get 读取缓存, 未命中时调用 load 加载数据并以 ttl 写入缓存。ttl <= 0 时使用 WithDefaultTTL
+// 指定的值。数据不存在时返回 ErrNotFound。
+func (c *Cache[T]) Get(
+	ctx context.Context, key string, ttl time.Duration, load LoadFunc[T],
+) (res T, err error) {
+	res, err = c.getFromRedis(ctx, key)
+	if err == nil {
+		c.count("hit")
+		return res, nil
+	}
+	if errors.Is(err, ErrNotFound) {
+		c.count("negativeHit")
+		return res, err
+	}
+	if !errors.Is(err, redis.Nil) {
+		// Redis 异常时降级为直接读取数据源
+		c.count("redis.fail")
+		log.Warnf("%s get key '%s' error: '%v'", cacheLogPrefix, key, err)
+	}
+	c.count("miss")
+
+	// 合并后的加载可能被多个调用方等待, 因此不能使用第一个调用方的 ctx, 而是使用剥离了取消信号的
+	// ctx 并单独设置超时。每个调用方只按照自己的 ctx 等待结果。
+	ch := c.group.DoChan(key, func() (any, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.opts.loadTimeout)
+		defer cancel()
+		return c.safeLoad(loadCtx, key, ttl, load)
+	})
+	select {
+	case r := <-ch:
+		if r.Err != nil {
+			return res, r.Err
+		}
+		res, _ = r.Val.(T)
+		return res, nil
+	case <-ctx.Done():
+		c.count("load.canceled")
+		return res, ctx.Err()
+	}
+}
+
+// Set 写入缓存, ttl <= 0 时使用 WithDefaultTTL 指定的值
+func (c *Cache[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	b, err := c.opts.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("marshal value for key '%s' error: %w", key, err)
+	}
+	return c.setToRedis(ctx, key, append([]byte{cacheFlagValue}, b...), c.ttl(ttl))
+}
+
+// Del 删除缓存
+func (c *Cache[T]) Del(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	cli, err := c.getter(ctx)
+	if err != nil {
+		return err
+	}
+	redisKeys := make([]string, 0, len(keys))
+	for _, k := range keys {
+		redisKeys = append(redisKeys, c.opts.keyPrefix+k)
+	}
+	return cli.Del(ctx, redisKeys...).Err()
+}
+
+// safeLoad 调用 load, 并将 panic 转为错误。DoChan 在独立的协程中执行, panic 无法被调用方捕获。
+func (c *Cache[T]) safeLoad(
+	ctx context.Context, key string, ttl time.Duration, load LoadFunc[T],
+) (res T, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			c.count("load.panic")
+			log.Errorf("%s load key '%s' panic: %v", cacheLogPrefix, key, e)
+			err = fmt.Errorf("load key '%s' panic: %v", key, e)
+		}
+	}()
+	return c.load(ctx, key, ttl, load)
+}
+
+func (c *Cache[T]) load(
+	ctx context.Context, key string, ttl time.Duration, load LoadFunc[T],
+) (res T, err error) {
+	res, err = load(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			c.count("load.notFound")
+			if c.opts.negativeTTL > 0 {
+				c.trySet(ctx, key, []byte{cacheFlagNotFound}, c.jitter(c.opts.negativeTTL))
+			}
+			return res, err
+		}
+		c.count("load.fail")
+		return res, err
+	}
+	c.count("load.succ")
+
+	b, err := c.opts.codec.Marshal(res)
+	if err != nil {
+		log.Errorf("%s marshal value for key '%s' error: '%v'", cacheLogPrefix, key, err)
+		return res, nil
+	}
+	c.trySet(ctx, key, append([]byte{cacheFlagValue}, b...), c.ttl(ttl))
+	return res, nil
+}
+
+func (c *Cache[T]) getFromRedis(ctx context.Context, key string) (res T, err error) {
+	cli, err := c.getter(ctx)
+	if err != nil {
+		return res, err
+	}
+	b, err := cli.Get(ctx, c.opts.keyPrefix+key).Bytes()
+	if err != nil {
+		return res, err
+	}
+	if len(b) == 0 {
+		return res, redis.Nil
+	}
+
+	switch b[0] {
+	case cacheFlagNotFound:
+		return res, ErrNotFound
+	case cacheFlagValue:
+		if err := c.opts.codec.Unmarshal(b[1:], &res); err != nil {
+			// 数据格式不对, 当作未命中处理, 由后续的加载覆盖
+			log.Warnf("%s unmarshal key '%s' error: '%v'", cacheLogPrefix, key, err)
+			return res, redis.Nil
+		}
+		return res, nil
+	default:
+		return res, redis.Nil
+	}
+}
+
+func (c *Cache[T]) setToRedis(ctx context.Context, key string, b []byte, ttl time.Duration) error {
+	cli, err := c.getter(ctx)
+	if err != nil {
+		return err
+	}
+	return cli.Set(ctx, c.opts.keyPrefix+key, b, ttl).Err()
+}
+
+func (c *Cache[T]) trySet(ctx context.Context, key string, b []byte, ttl time.Duration) {
+	if err := c.setToRedis(ctx, key, b, ttl); err != nil {
+		c.count("redis.fail")
+		log.Warnf("%s set key '%s' error: '%v'", cacheLogPrefix, key, err)
+	}
+}
+
+// ttl 返回带随机抖动的实际过期时间
+func (c *Cache[T]) ttl(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		ttl = c.opts.defaultTTL
+	}
+	return c.jitter(ttl)
+}
+
+func (c *Cache[T]) jitter(ttl time.Duration) time.Duration {
+	if c.opts.jitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	maxJitter := int64(float64(ttl) * c.opts.jitter)
+	if maxJitter <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Int64N(maxJitter))
+}
+
+func (c *Cache[T]) count(name string) {
+	count("cache." + name)
+}
@@ -0,0 +1,110 @@
+package redis
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// 默认缓存时长
+	defaultCacheTTL = 10 * time.Minute
+	// 默认的数据加载超时时间
+	defaultCacheLoadTimeout = 5 * time.Second
+)
+
+// Codec 表示缓存值的序列化方式
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec 使用 encoding/json 序列化, 是 Cache 的默认 Codec
+type JSONCodec struct{}
+
+// Marshal 实现 Codec
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal 实现 Codec
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// CacheOption 表示 NewCache 的额外参数
+type CacheOption func(*cacheOptions)
+
+type cacheOptions struct {
+	codec       Codec
+	keyPrefix   string
+	defaultTTL  time.Duration
+	negativeTTL time.Duration
+	jitter      float64
+	loadTimeout time.Duration
+}
+
+func mergeCacheOptions(opts []CacheOption) cacheOptions {
+	opt := cacheOptions{
+		codec:       JSONCodec{},
+		defaultTTL:  defaultCacheTTL,
+		loadTimeout: defaultCacheLoadTimeout,
+	}
+	for _, o := range opts {
+		if o != nil {
+			o(&opt)
+		}
+	}
+	return opt
+}
+
+// WithCodec 指定缓存值的序列化方式, 默认为 JSONCodec
+func WithCodec(codec Codec) CacheOption {
+	return func(o *cacheOptions) {
+		if codec != nil {
+			o.codec = codec
+		}
+	}
+}
+
+// WithKeyPrefix 给所有的 Redis key 加上前缀
+func WithKeyPrefix(prefix string) CacheOption {
+	return func(o *cacheOptions) {
+		o.keyPrefix = prefix
+	}
+}
+
+// WithDefaultTTL 指定调用时未指定 ttl 时的默认缓存时长, 默认 10 分钟
+func WithDefaultTTL(ttl time.Duration) CacheOption {
+	return func(o *cacheOptions) {
+		if ttl > 0 {
+			o.defaultTTL = ttl
+		}
+	}
+}
+
+// WithNegativeTTL 开启 "数据不存在" 结果的缓存, 缓存时长为 ttl。默认不开启。
+func WithNegativeTTL(ttl time.Duration) CacheOption {
+	return func(o *cacheOptions) {
+		o.negativeTTL = ttl
+	}
+}
+
+// WithTTLJitter 给缓存时长加上 [0, ttl * fraction) 的随机抖动, 避免大量 key 同时过期。
+// 默认不抖动。
+func WithTTLJitter(fraction float64) CacheOption {
+	return func(o *cacheOptions) {
+		if fraction > 0 {
+			o.jitter = fraction
+		}
+	}
+}
+
+// WithLoadTimeout 指定缓存未命中时 LoadFunc 的超时时间, 默认 5 秒。同一个 key 的并发加载会被
+// 合并, 因此加载不受调用方 ctx 的取消影响, 只受这个超时控制。
+func WithLoadTimeout(timeout time.Duration) CacheOption {
+	return func(o *cacheOptions) {
+		if timeout > 0 {
+			o.loadTimeout = timeout
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	amcredis "github.com/Andrew-M-C/trpc-go-utils/client/redis"
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/redis/go-redis/v9"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+var (
+	cv = convey.Convey
+	so = convey.So
+	eq = convey.ShouldEqual
+
+	isNil  = convey.ShouldBeNil
+	notNil = convey.ShouldNotBeNil
+	isTrue = convey.ShouldBeTrue
+)
+
+// newMiniredis 启动一个 miniredis, 返回指向它的 Getter
+func newMiniredis(t *testing.T) (*miniredis.Miniredis, amcredis.Getter) {
+	mr := miniredis.RunT(t)
+	cli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = cli.Close() })
+	return mr, func(context.Context) (redis.UniversalClient, error) {
+		return cli, nil
+	}
+}
+
+// waitFor 轮询直到 cond 返回 true, 超时返回 false
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return true
+}
+
+type user struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestCache(t *testing.T) {
+	cv("未命中时加载并写回, 之后命中", t, func() {
+		mr, getter := newMiniredis(t)
+		c := amcredis.NewCache[user](getter, amcredis.WithKeyPrefix("user:"))
+		ctx := context.Background()
+
+		loads := atomic.Int64{}
+		load := func(_ context.Context, key string) (user, error) {
+			loads.Add(1)
+			return user{ID: 1, Name: "Alice"}, nil
+		}
+
+		u, err := c.Get(ctx, "1", time.Minute, load)
+		so(err, isNil)
+		so(u.Name, eq, "Alice")
+		so(mr.Exists("user:1"), isTrue)
+		so(mr.TTL("user:1"), eq, time.Minute)
+
+		u, err = c.Get(ctx, "1", time.Minute, load)
+		so(err, isNil)
+		so(u.Name, eq, "Alice")
+		so(loads.Load(), eq, 1)
+
+		so(c.Del(ctx, "1"), isNil)
+		so(mr.Exists("user:1"), eq, false)
+	})
+
+	cv("缓存不存在的结果", t, func() {
+		mr, getter := newMiniredis(t)
+		c := amcredis.NewCache[user](getter, amcredis.WithNegativeTTL(time.Second))
+		ctx := context.Background()
+
+		loads := atomic.Int64{}
+		load := func(context.Context, string) (user, error) {
+			loads.Add(1)
+			return user{}, amcredis.ErrNotFound
+		}
+
+		_, err := c.Get(ctx, "404", 0, load)
+		so(errors.Is(err, amcredis.ErrNotFound), isTrue)
+		_, err = c.Get(ctx, "404", 0, load)
+		so(errors.Is(err, amcredis.ErrNotFound), isTrue)
+		so(loads.Load(), eq, 1)
+
+		mr.FastForward(2 * time.Second)
+		_, err = c.Get(ctx, "404", 0, load)
+		so(errors.Is(err, amcredis.ErrNotFound), isTrue)
+		so(loads.Load(), eq, 2)
+	})
+
+	cv("并发未命中只加载一次", t, func() {
+		_, getter := newMiniredis(t)
+
+		gets := atomic.Int64{}
+		counted := func(ctx context.Context) (redis.UniversalClient, error) {
+			gets.Add(1)
+			return getter(ctx)
+		}
+		c := amcredis.NewCache[user](counted)
+
+		const n = 10
+		loads := atomic.Int64{}
+		unblock := make(chan struct{})
+		load := func(context.Context, string) (user, error) {
+			loads.Add(1)
+			<-unblock
+			return user{ID: 1, Name: "Alice"}, nil
+		}
+
+		wg := sync.WaitGroup{}
+		results := make([]user, n)
+		errs := make([]error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i], errs[i] = c.Get(context.Background(), "1", 0, load)
+			}()
+		}
+		// 所有调用方都已经读取过 Redis, 稍等片刻让它们进入等待
+		so(waitFor(func() bool { return gets.Load() >= n }), isTrue)
+		time.Sleep(50 * time.Millisecond)
+		close(unblock)
+		wg.Wait()
+
+		so(loads.Load(), eq, 1)
+		for i := 0; i < n; i++ {
+			so(errs[i], isNil)
+			so(results[i].Name, eq, "Alice")
+		}
+	})
+
+	cv("调用方的 ctx 结束不影响其他等待方和加载", t, func() {
+		mr, getter := newMiniredis(t)
+		c := amcredis.NewCache[user](getter)
+
+		started := make(chan struct{})
+		unblock := make(chan struct{})
+		var loadErr atomic.Value
+		load := func(ctx context.Context, _ string) (user, error) {
+			close(started)
+			<-unblock
+			if err := ctx.Err(); err != nil {
+				loadErr.Store(err)
+			}
+			return user{ID: 1, Name: "Alice"}, nil
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		firstErr := make(chan error, 1)
+		go func() {
+			_, err := c.Get(ctx, "1", 0, load)
+			firstErr <- err
+		}()
+		<-started
+
+		secondRes := make(chan user, 1)
+		go func() {
+			u, _ := c.Get(context.Background(), "1", 0, load)
+			secondRes <- u
+		}()
+
+		cancel()
+		so(errors.Is(<-firstErr, context.Canceled), isTrue)
+
+		close(unblock)
+		so((<-secondRes).Name, eq, "Alice")
+		so(loadErr.Load(), eq, nil)
+		so(waitFor(func() bool { return mr.Exists("1") }), isTrue)
+	})
+
+	cv("加载超时", t, func() {
+		_, getter := newMiniredis(t)
+		c := amcredis.NewCache[user](getter, amcredis.WithLoadTimeout(50*time.Millisecond))
+
+		_, err := c.Get(context.Background(), "1", 0, func(ctx context.Context, _ string) (user, error) {
+			<-ctx.Done()
+			return user{}, ctx.Err()
+		})
+		so(err, notNil)
+		so(errors.Is(err, context.DeadlineExceeded), isTrue)
+	})
+
+	cv("加载 panic 转为错误", t, func() {
+		_, getter := newMiniredis(t)
+		c := amcredis.NewCache[user](getter)
+
+		_, err := c.Get(context.Background(), "1", 0, func(context.Context, string) (user, error) {
+			panic("oops")
+		})
+		so(err, notNil)
+	})
+}
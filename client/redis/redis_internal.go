@@ -0,0 +1,16 @@
+package redis
+
+import "github.com/Andrew-M-C/trpc-go-utils/metrics"
+
+const metricsPrefix = "amc.utils.redis."
+
+func count(name string) {
+	metrics.IncrCounter(metricsPrefix+name, 1)
+}
+
+// E 表示内部错误
+type E string
+
+func (e E) Error() string {
+	return string(e)
+}
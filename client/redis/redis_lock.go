@@ -0,0 +1,270 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+	"trpc.group/trpc-go/trpc-go/log"
+)
+
+const (
+	// ErrLockNotObtained 表示锁已被其他持有者占用
+	ErrLockNotObtained = E("amc redis lock: not obtained")
+	// ErrLockLost 表示锁在持有期间丢失, 比如续期失败或者已被其他持有者抢占
+	ErrLockLost = E("amc redis lock: lost")
+	// ErrLockNotHeld 表示释放锁时发现锁已经不属于当前持有者
+	ErrLockNotHeld = E("amc redis lock: not held")
+)
+
+const lockLogPrefix = "[amc.utils.redis.lock]"
+
+var (
+	// 只有 token 匹配时才续期
+	renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+	// 只有 token 匹配时才删除
+	releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+)
+
+// Locker 基于 Redis 的分布式锁
+type Locker struct {
+	getter  Getter
+	acquire Acquirer
+	opts    lockOptions
+}
+
+// NewLocker 新建一个分布式锁工具, getter 一般为 ClientGetter 的返回值。每次加锁、续期、释放都会
+// 通过 getter 重新获取 client。推荐使用 NewLockerWithAcquirer。
+func NewLocker(getter Getter, opts ...LockOption) *Locker {
+	return &Locker{
+		getter: getter,
+		opts:   mergeLockOptions(opts),
+	}
+}
+
+// NewLockerWithAcquirer 新建一个分布式锁工具, acquirer 一般为 ClientAcquirer 的返回值。加锁成功
+// 之后, 续期和释放都使用加锁时占用的 client, 直至 Unlock 时才归还, 因此在持有锁期间 ClientBuffer
+// 替换了底层 client 也不会导致续期失败。
+func NewLockerWithAcquirer(acquirer Acquirer, opts ...LockOption) *Locker {
+	return &Locker{
+		acquire: acquirer,
+		opts:    mergeLockOptions(opts),
+	}
+}
+
+// Mutex 表示一个已经持有的锁, 在释放之前会在后台自动续期。即便锁已经丢失, 也需要调用 Unlock
+// 以归还占用的 client。
+type Mutex struct {
+	locker  *Locker
+	key     string
+	token   string
+	ttl     time.Duration
+	cli     redis.UniversalClient // 仅在通过 NewLockerWithAcquirer 创建时有效
+	release func()
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	lost   chan struct{}
+	stop   chan struct{}
+	done   chan struct{} // 续期协程退出时关闭
+	once   sync.Once
+}
+
+// Lock 加锁, 如果锁被占用则按照 WithRetryInterval 的间隔重试, 直至成功或者 ctx 结束。
+// ttl 为锁的租期, 不能小于 1 毫秒, 持有期间每 ttl/3 续期一次。
+func (l *Locker) Lock(ctx context.Context, key string, ttl time.Duration) (*Mutex, error) {
+	for {
+		m, err := l.TryLock(ctx, key, ttl)
+		if err == nil {
+			return m, nil
+		}
+		if !errors.Is(err, ErrLockNotObtained) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ErrLockNotObtained, ctx.Err())
+		case <-time.After(l.opts.retryInterval):
+		}
+	}
+}
+
+// TryLock 尝试加锁一次, 锁被占用时返回 ErrLockNotObtained。ttl 不能小于 1 毫秒。
+func (l *Locker) TryLock(ctx context.Context, key string, ttl time.Duration) (*Mutex, error) {
+	// Redis 的过期时间精度为毫秒, 续期间隔为 ttl/3
+	if ttl < time.Millisecond {
+		return nil, fmt.Errorf("invalid lock ttl %v, should be at least 1ms", ttl)
+	}
+	m := &Mutex{
+		locker:  l,
+		key:     key,
+		token:   newLockToken(),
+		ttl:     ttl,
+		release: func() {},
+		lost:    make(chan struct{}),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if l.acquire != nil {
+		cli, release, err := l.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		m.cli, m.release = cli, sync.OnceFunc(release)
+	}
+
+	cli, err := m.client(ctx)
+	if err != nil {
+		m.release()
+		return nil, err
+	}
+	ok, err := cli.SetNX(ctx, key, m.token, ttl).Result()
+	if err != nil {
+		m.release()
+		return nil, fmt.Errorf("set lock key '%s' error: %w", key, err)
+	}
+	if !ok {
+		m.release()
+		return nil, ErrLockNotObtained
+	}
+
+	count("lock.obtain")
+	// 保留 ctx 中的值 (比如 trace ID), 但是不继承其取消
+	m.ctx, m.cancel = context.WithCancelCause(context.WithoutCancel(ctx))
+	go m.renewRoutine()
+	return m, nil
+}
+
+// Context 返回一个在锁丢失或者释放时被取消的 context, 锁丢失时 context.Cause 为 ErrLockLost
+func (m *Mutex) Context() context.Context {
+	return m.ctx
+}
+
+// Lost 返回一个在锁丢失时关闭的 channel, 主动 Unlock 不会关闭它
+func (m *Mutex) Lost() <-chan struct{} {
+	return m.lost
+}
+
+// Key 返回锁的 key
+func (m *Mutex) Key() string {
+	return m.key
+}
+
+// Unlock 释放锁。如果锁已经不属于当前持有者, 返回 ErrLockNotHeld
+func (m *Mutex) Unlock(ctx context.Context) error {
+	m.once.Do(func() {
+		close(m.stop)
+		m.cancel(nil)
+	})
+
+	// 等待续期协程退出之后再归还 client
+	defer func() {
+		<-m.done
+		m.release()
+	}()
+
+	cli, err := m.client(ctx)
+	if err != nil {
+		return err
+	}
+	res, err := releaseScript.Run(ctx, cli, []string{m.key}, m.token).Int64()
+	if err != nil {
+		return fmt.Errorf("release lock key '%s' error: %w", m.key, err)
+	}
+	if res == 0 {
+		return ErrLockNotHeld
+	}
+	count("lock.release")
+	return nil
+}
+
+func (m *Mutex) renewRoutine() {
+	defer close(m.done)
+
+	interval := m.ttl / 3
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	leaseUntil := time.Now().Add(m.ttl)
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-tick.C:
+		}
+
+		start := time.Now()
+		held, err := m.renew(interval)
+		switch {
+		case err == nil && held:
+			leaseUntil = start.Add(m.ttl)
+			continue
+		case err == nil && !held:
+			log.Warnf("%s lock '%s' is taken by others", lockLogPrefix, m.key)
+		case time.Now().Before(leaseUntil):
+			// 续期失败但是租期还没过, 下次再试
+			count("lock.renew.fail")
+			log.Warnf("%s renew lock '%s' error: '%v'", lockLogPrefix, m.key, err)
+			continue
+		default:
+			log.Errorf("%s renew lock '%s' error and lease expired: '%v'", lockLogPrefix, m.key, err)
+		}
+
+		m.markLost()
+		return
+	}
+}
+
+func (m *Mutex) renew(timeout time.Duration) (held bool, err error) {
+	ctx, cancel := context.WithTimeout(m.ctx, timeout)
+	defer cancel()
+
+	cli, err := m.client(ctx)
+	if err != nil {
+		return false, err
+	}
+	res, err := renewScript.Run(ctx, cli, []string{m.key}, m.token, m.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+// client 返回加锁时占用的 client, 没有占用时通过 getter 获取
+func (m *Mutex) client(ctx context.Context) (redis.UniversalClient, error) {
+	if m.cli != nil {
+		return m.cli, nil
+	}
+	return m.locker.getter(ctx)
+}
+
+func (m *Mutex) markLost() {
+	m.once.Do(func() {
+		count("lock.lost")
+		close(m.lost)
+		close(m.stop)
+		m.cancel(ErrLockLost)
+	})
+}
+
+func newLockToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,36 @@
+package redis
+
+import "time"
+
+const (
+	// Lock 默认的重试间隔
+	defaultLockRetryInterval = 100 * time.Millisecond
+)
+
+// LockOption 表示 NewLocker 的额外参数
+type LockOption func(*lockOptions)
+
+type lockOptions struct {
+	retryInterval time.Duration
+}
+
+func mergeLockOptions(opts []LockOption) lockOptions {
+	opt := lockOptions{
+		retryInterval: defaultLockRetryInterval,
+	}
+	for _, o := range opts {
+		if o != nil {
+			o(&opt)
+		}
+	}
+	return opt
+}
+
+// WithRetryInterval 指定 Lock 在锁被占用时的重试间隔, 默认 100 毫秒
+func WithRetryInterval(interval time.Duration) LockOption {
+	return func(o *lockOptions) {
+		if interval > 0 {
+			o.retryInterval = interval
+		}
+	}
+}
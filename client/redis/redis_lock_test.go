@@ -0,0 +1,115 @@
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	amcredis "github.com/Andrew-M-C/trpc-go-utils/client/redis"
+	redis "github.com/redis/go-redis/v9"
+)
+
+func TestLocker(t *testing.T) {
+	cv("加锁与释放", t, func() {
+		mr, getter := newMiniredis(t)
+		l := amcredis.NewLocker(getter)
+		ctx := context.Background()
+
+		m, err := l.TryLock(ctx, "lock:1", time.Second)
+		so(err, isNil)
+		so(mr.Exists("lock:1"), isTrue)
+
+		_, err = l.TryLock(ctx, "lock:1", time.Second)
+		so(errors.Is(err, amcredis.ErrLockNotObtained), isTrue)
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, err = l.Lock(timeoutCtx, "lock:1", time.Second)
+		so(errors.Is(err, amcredis.ErrLockNotObtained), isTrue)
+
+		so(m.Unlock(ctx), isNil)
+		so(mr.Exists("lock:1"), eq, false)
+		so(m.Context().Err(), notNil)
+
+		m, err = l.TryLock(ctx, "lock:1", time.Second)
+		so(err, isNil)
+		so(m.Unlock(ctx), isNil)
+	})
+
+	cv("ttl 小于 1 毫秒时拒绝加锁", t, func() {
+		_, getter := newMiniredis(t)
+		l := amcredis.NewLocker(getter)
+
+		for _, ttl := range []time.Duration{0, -time.Second, 2, time.Millisecond - 1} {
+			_, err := l.TryLock(context.Background(), "lock:ttl", ttl)
+			so(err, notNil)
+		}
+	})
+
+	cv("持有期间自动续期", t, func() {
+		mr, getter := newMiniredis(t)
+		l := amcredis.NewLocker(getter)
+		ctx := context.Background()
+
+		m, err := l.TryLock(ctx, "lock:renew", 300*time.Millisecond)
+		so(err, isNil)
+		defer func() { _ = m.Unlock(ctx) }()
+
+		mr.FastForward(250 * time.Millisecond)
+		so(mr.TTL("lock:renew") <= 50*time.Millisecond, isTrue)
+		so(waitFor(func() bool { return mr.TTL("lock:renew") > 200*time.Millisecond }), isTrue)
+		so(mr.Exists("lock:renew"), isTrue)
+	})
+
+	cv("只有持有者才能释放, 被抢占时通知锁丢失", t, func() {
+		mr, getter := newMiniredis(t)
+		l := amcredis.NewLocker(getter)
+		ctx := context.Background()
+
+		m, err := l.TryLock(ctx, "lock:owner", 300*time.Millisecond)
+		so(err, isNil)
+
+		so(mr.Set("lock:owner", "someone-else"), isNil)
+		select {
+		case <-m.Lost():
+		case <-time.After(2 * time.Second):
+			t.Fatal("lock lost not notified")
+		}
+		so(errors.Is(context.Cause(m.Context()), amcredis.ErrLockLost), isTrue)
+
+		err = m.Unlock(ctx)
+		so(errors.Is(err, amcredis.ErrLockNotHeld), isTrue)
+		v, err := mr.Get("lock:owner")
+		so(err, isNil)
+		so(v, eq, "someone-else")
+	})
+
+	cv("通过 Acquirer 加锁时, Unlock 之后才归还 client", t, func() {
+		_, getter := newMiniredis(t)
+		acquired := atomic.Int64{}
+		released := atomic.Int64{}
+		acquirer := func(ctx context.Context) (redis.UniversalClient, func(), error) {
+			cli, err := getter(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			acquired.Add(1)
+			return cli, func() { released.Add(1) }, nil
+		}
+		l := amcredis.NewLockerWithAcquirer(acquirer)
+		ctx := context.Background()
+
+		m, err := l.TryLock(ctx, "lock:acquire", time.Second)
+		so(err, isNil)
+		_, err = l.TryLock(ctx, "lock:acquire", time.Second)
+		so(errors.Is(err, amcredis.ErrLockNotObtained), isTrue)
+		so(acquired.Load(), eq, 2)
+		so(released.Load(), eq, 1) // 加锁失败时立即归还
+
+		so(m.Unlock(ctx), isNil)
+		so(m.Unlock(ctx), notNil)
+		so(released.Load(), eq, 2)
+	})
+}
@@ -0,0 +1,79 @@
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Andrew-M-C/trpc-go-utils/client/buffer"
+	amcredis "github.com/Andrew-M-C/trpc-go-utils/client/redis"
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/redis/go-redis/v9"
+	"trpc.group/trpc-go/trpc-go/client"
+)
+
+func setRedisTarget(name, target string) {
+	_ = client.RegisterClientConfig(name, &client.BackendConfig{Target: target})
+}
+
+func TestBufferOptions(t *testing.T) {
+	cv("默认使用 PING 检查新的客户端, 失败时继续使用旧的客户端", t, func() {
+		amcredis.SetBufferOptions(
+			buffer.WithHealthCheckTimeout[redis.UniversalClient](200*time.Millisecond),
+			buffer.WithRetryBackoff[redis.UniversalClient](time.Hour, time.Hour),
+		)
+		defer amcredis.SetBufferOptions()
+
+		const name = "trpc.redis.test.buffer"
+		setRedisTarget(name, fmt.Sprintf("redis://%s/0", miniredis.RunT(t).Addr()))
+		getter := amcredis.ClientGetter(name)
+		ctx := context.Background()
+
+		cli, err := getter(ctx)
+		so(err, isNil)
+		so(cli.Set(ctx, "k", "v", 0).Err(), isNil)
+
+		// 新的配置不可用, 继续使用旧的客户端
+		setRedisTarget(name, "redis://127.0.0.1:1/0")
+		curr, err := getter(ctx)
+		so(err, isNil)
+		so(curr, eq, cli)
+		so(curr.Get(ctx, "k").Val(), eq, "v")
+
+		// 新的配置可用, 替换旧的客户端
+		setRedisTarget(name, fmt.Sprintf("redis://%s/0", miniredis.RunT(t).Addr()))
+		curr, err = getter(ctx)
+		so(err, isNil)
+		so(curr == cli, eq, false)
+		so(curr.Get(ctx, "k").Err(), eq, redis.Nil)
+	})
+
+	cv("buffer.WithHealthCheck 替换默认的 PING 检查", t, func() {
+		checks := atomic.Int64{}
+		amcredis.SetBufferOptions(
+			buffer.WithHealthCheck(func(context.Context, redis.UniversalClient) error {
+				checks.Add(1)
+				return errors.New("unhealthy")
+			}),
+			buffer.WithRetryBackoff[redis.UniversalClient](time.Hour, time.Hour),
+		)
+		defer amcredis.SetBufferOptions()
+
+		const name = "trpc.redis.test.buffer.custom"
+		setRedisTarget(name, fmt.Sprintf("redis://%s/0", miniredis.RunT(t).Addr()))
+		getter := amcredis.ClientGetter(name)
+		ctx := context.Background()
+
+		cli, err := getter(ctx)
+		so(err, isNil)
+
+		setRedisTarget(name, fmt.Sprintf("redis://%s/0", miniredis.RunT(t).Addr()))
+		curr, err := getter(ctx)
+		so(err, isNil)
+		so(curr, eq, cli)
+		so(checks.Load(), eq, 1)
+	})
+}
@@ -0,0 +1,205 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Andrew-M-C/trpc-go-utils/client/localcache"
+	redis "github.com/redis/go-redis/v9"
+	"trpc.group/trpc-go/trpc-go/log"
+)
+
+const tieredLogPrefix = "[amc.utils.redis.tiered]"
+
+// TieredCache 两级缓存: 以本地缓存 localcache.Cache 作为 L1, Redis 缓存 Cache 作为 L2。
+// 通过 Set / Del / Invalidate 修改数据时, 会通过 Redis pub/sub 广播给所有实例, 淘汰各实例
+// 中的 L1 数据。
+type TieredCache[T any] struct {
+	l1   localcache.Cache[T]
+	l2   *Cache[T]
+	opts tieredOptions
+
+	instanceID string
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+// tieredMessage 表示广播的失效消息
+type tieredMessage struct {
+	Source string   `json:"src"`
+	Keys   []string `json:"keys"`
+}
+
+// NewTieredCache 新建一个两级缓存, 并在后台订阅失效广播。不再使用时请调用 Close。
+func NewTieredCache[T any](
+	l1 localcache.Cache[T], l2 *Cache[T], opts ...TieredOption,
+) *TieredCache[T] {
+	c := &TieredCache[T]{
+		l1:         l1,
+		l2:         l2,
+		opts:       mergeTieredOptions(opts, l2.opts.keyPrefix),
+		instanceID: newInstanceID(),
+		stop:       make(chan struct{}),
+	}
+	go c.subscribeRoutine()
+	return c
+}
+
+// Get 依次读取 L1、L2, 都未命中时调用 load 加载数据, 并回填 L2 (以 ttl 为过期时间) 和 L1
+// (以 WithL1TTL 为过期时间)。数据不存在时返回 ErrNotFound。
+func (c *TieredCache[T]) Get(
+	ctx context.Context, key string, ttl time.Duration, load LoadFunc[T],
+) (T, error) {
+	if v, ok := c.l1.Get(key); ok {
+		count("tiered.l1.hit")
+		return v, nil
+	}
+	count("tiered.l1.miss")
+
+	v, err := c.l2.Get(ctx, key, ttl, load)
+	if err != nil {
+		return v, err
+	}
+	c.l1.SetWithExpire(key, v, c.opts.l1TTL)
+	return v, nil
+}
+
+// Set 写入 L2 和本实例的 L1, 并广播让其他实例淘汰 L1
+func (c *TieredCache[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if err := c.l2.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	c.l1.SetWithExpire(key, value, c.opts.l1TTL)
+	return c.publish(ctx, key)
+}
+
+// Del 删除 L2 和所有实例的 L1 中的数据
+func (c *TieredCache[T]) Del(ctx context.Context, keys ...string) error {
+	if err := c.l2.Del(ctx, keys...); err != nil {
+		return err
+	}
+	return c.Invalidate(ctx, keys...)
+}
+
+// Invalidate 只淘汰所有实例的 L1 中的数据, 不修改 L2
+func (c *TieredCache[T]) Invalidate(ctx context.Context, keys ...string) error {
+	for _, k := range keys {
+		c.l1.Del(k)
+	}
+	return c.publish(ctx, keys...)
+}
+
+// Close 停止订阅失效广播, 不会关闭 L1 和 Redis client
+func (c *TieredCache[T]) Close() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
+func (c *TieredCache[T]) publish(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	b, _ := json.Marshal(tieredMessage{
+		Source: c.instanceID,
+		Keys:   keys,
+	})
+
+	cli, err := c.l2.getter(ctx)
+	if err != nil {
+		return err
+	}
+	if err := cli.Publish(ctx, c.opts.channel, b).Err(); err != nil {
+		count("tiered.publish.fail")
+		return err
+	}
+	return nil
+}
+
+func (c *TieredCache[T]) subscribeRoutine() {
+	for {
+		err := c.subscribe()
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+
+		count("tiered.subscribe.fail")
+		log.Warnf("%s subscribe channel '%s' interrupted: '%v'", tieredLogPrefix, c.opts.channel, err)
+
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(c.opts.resubscribeInterval):
+		}
+	}
+}
+
+// subscribe 订阅失效广播直至出错、底层 client 被替换或者 Close
+func (c *TieredCache[T]) subscribe() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cli, err := c.l2.getter(ctx)
+	if err != nil {
+		return err
+	}
+	sub := cli.Subscribe(ctx, c.opts.channel)
+	defer sub.Close()
+
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+	// 订阅成功之前可能错过了失效消息, 清空 L1 保证一致性
+	c.l1.Clear()
+
+	// 定期检查底层 client 是否被 ClientBuffer 替换, 是的话重新订阅
+	check := time.NewTicker(c.opts.resubscribeInterval)
+	defer check.Stop()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-c.stop:
+			return nil
+
+		case <-check.C:
+			if curr, err := c.l2.getter(ctx); err == nil && curr != cli {
+				return errors.New("redis client replaced")
+			}
+
+		case msg, ok := <-ch:
+			if !ok {
+				return errors.New("subscription channel closed")
+			}
+			c.handleMessage(msg)
+		}
+	}
+}
+
+func (c *TieredCache[T]) handleMessage(msg *redis.Message) {
+	var m tieredMessage
+	if err := json.Unmarshal([]byte(msg.Payload), &m); err != nil {
+		log.Warnf("%s invalid message '%s': '%v'", tieredLogPrefix, msg.Payload, err)
+		return
+	}
+	if m.Source == c.instanceID {
+		return // 本实例发出的消息, 已经在本地处理过了
+	}
+	for _, k := range m.Keys {
+		c.l1.Del(k)
+	}
+	count("tiered.invalidate.recv")
+}
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,55 @@
+package redis
+
+import "time"
+
+const (
+	// L1 默认缓存时长。即便失效广播丢失, L1 中的数据最多也只会旧这么久
+	defaultTieredL1TTL = time.Minute
+	// 订阅中断后重新订阅的间隔, 同时也是检查底层 client 是否被替换的间隔
+	defaultTieredResubscribeInterval = 5 * time.Second
+	// 默认的失效广播 channel 前缀
+	defaultTieredChannelPrefix = "amc.utils.redis.tiered.invalidate."
+)
+
+// TieredOption 表示 NewTieredCache 的额外参数
+type TieredOption func(*tieredOptions)
+
+type tieredOptions struct {
+	l1TTL               time.Duration
+	channel             string
+	resubscribeInterval time.Duration
+}
+
+func mergeTieredOptions(opts []TieredOption, keyPrefix string) tieredOptions {
+	opt := tieredOptions{
+		l1TTL:               defaultTieredL1TTL,
+		channel:             defaultTieredChannelPrefix + keyPrefix,
+		resubscribeInterval: defaultTieredResubscribeInterval,
+	}
+	for _, o := range opts {
+		if o != nil {
+			o(&opt)
+		}
+	}
+	return opt
+}
+
+// WithL1TTL 指定 L1 的缓存时长, 默认 1 分钟
+func WithL1TTL(ttl time.Duration) TieredOption {
+	return func(o *tieredOptions) {
+		if ttl > 0 {
+			o.l1TTL = ttl
+		}
+	}
+}
+
+// WithInvalidateChannel 指定失效广播使用的 Redis pub/sub channel。默认为
+// "amc.utils.redis.tiered.invalidate." 加上 L2 的 key 前缀, 因此不同的缓存请使用不同的
+// key 前缀或者 channel。
+func WithInvalidateChannel(channel string) TieredOption {
+	return func(o *tieredOptions) {
+		if channel != "" {
+			o.channel = channel
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Andrew-M-C/trpc-go-utils/client/localcache"
+	amcredis "github.com/Andrew-M-C/trpc-go-utils/client/redis"
+)
+
+func TestTieredCache(t *testing.T) {
+	cv("修改数据时淘汰其他实例的 L1", t, func() {
+		const channel = "test.tiered.invalidate"
+		mr, getter := newMiniredis(t)
+		newTiered := func() *amcredis.TieredCache[user] {
+			l2 := amcredis.NewCache[user](getter, amcredis.WithKeyPrefix("tiered:"))
+			return amcredis.NewTieredCache(localcache.New[user](), l2, amcredis.WithInvalidateChannel(channel))
+		}
+		a, b := newTiered(), newTiered()
+		defer a.Close()
+		defer b.Close()
+
+		// 订阅成功时会清空 L1, 等两个实例都订阅之后再开始
+		so(waitFor(func() bool { return mr.PubSubNumSub(channel)[channel] == 2 }), isTrue)
+
+		ctx := context.Background()
+		loads := atomic.Int64{}
+		name := atomic.Value{}
+		name.Store("Alice")
+		load := func(context.Context, string) (user, error) {
+			loads.Add(1)
+			return user{ID: 1, Name: name.Load().(string)}, nil
+		}
+		get := func(c *amcredis.TieredCache[user]) string {
+			u, err := c.Get(ctx, "1", time.Minute, load)
+			if err != nil {
+				return err.Error()
+			}
+			return u.Name
+		}
+
+		so(get(a), eq, "Alice")
+		so(get(b), eq, "Alice")
+		so(loads.Load(), eq, 1)
+
+		// b 的 L1 中仍然是旧数据, 只有收到 a 的广播之后才能读到新数据
+		so(a.Set(ctx, "1", user{ID: 1, Name: "Bob"}, time.Minute), isNil)
+		so(get(a), eq, "Bob")
+		so(waitFor(func() bool { return get(b) == "Bob" }), isTrue)
+
+		// Del 同时删除 L2, 两个实例都需要重新加载
+		name.Store("Carol")
+		so(b.Del(ctx, "1"), isNil)
+		so(mr.Exists("tiered:1"), eq, false)
+		so(waitFor(func() bool { return get(a) == "Carol" }), isTrue)
+		so(get(b), eq, "Carol")
+		so(loads.Load(), eq, 2)
+	})
+
+	cv("数据不存在", t, func() {
+		_, getter := newMiniredis(t)
+		c := amcredis.NewTieredCache(localcache.New[user](), amcredis.NewCache[user](getter))
+		defer c.Close()
+
+		_, err := c.Get(context.Background(), "404", 0, func(context.Context, string) (user, error) {
+			return user{}, amcredis.ErrNotFound
+		})
+		so(errors.Is(err, amcredis.ErrNotFound), isTrue)
+	})
+}
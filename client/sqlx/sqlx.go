@@ -4,6 +4,8 @@ package sqlx
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 
 	"github.com/jmoiron/sqlx"
 	"trpc.group/trpc-go/trpc-database/mysql"
@@ -17,7 +19,7 @@ func ClientGetter(
 	return func(ctx context.Context) (Client, error) {
 		// trpc mysql 的实现本身就自带了动态 client, 因此可以直接返回
 		cli := mysql.NewUnsafeClient(name, opts...)
-		return &clientWrapper{db: cli}, nil
+		return &clientWrapper{name: name, db: cli}, nil
 	}
 }
 
@@ -25,32 +27,106 @@ func ClientGetter(
 type TxFunc func(context.Context, *sqlx.Tx) error
 
 // Client 简化 sqlx 接口, 尽量
+//
+// 在 TransactionContext 的回调中, 使用回调传入的 ctx 调用同一个数据库的 Client 方法时, 会自动
+// 在该事务中执行; 嵌套调用 TransactionContext 则使用 SAVEPOINT, 只有最外层才会提交或回滚,
+// 嵌套调用时的 TxOption 会被忽略。fn 返回错误或 panic 时回滚事务, panic 会被转为错误返回。
+//
+// "IN (?)" 对应的参数为切片时会自动展开为 "IN (?, ?, ?)", 其他位置的参数 (包括 []byte 和
+// json.RawMessage 等切片) 原样传入。Named 系列方法使用 ":name" 形式的命名参数, arg 为结构体或
+// map; arg 为切片时可用于批量插入。
 type Client interface {
 	QueryContext(ctx context.Context, next mysql.NextFunc, query string, args ...any) error
 	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
 	SelectContext(ctx context.Context, dest any, query string, args ...any) error
-	TransactionContext(ctx context.Context, fn TxFunc) error
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error)
+	NamedSelectContext(ctx context.Context, dest any, query string, arg any) error
+	TransactionContext(ctx context.Context, fn TxFunc, opts ...TxOption) error
 }
 
 type clientWrapper struct {
-	db mysql.Client
+	name string
+	db   mysql.Client
+}
+
+// prepare 展开切片参数, trpc mysql 使用 "?" 作为占位符
+func (c *clientWrapper) prepare(query string, args []any) (string, []any, error) {
+	return prepare(query, args, sqlx.QUESTION)
 }
 
 func (c *clientWrapper) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	query, args, err := c.prepare(query, args)
+	if err != nil {
+		return nil, err
+	}
+	if st := txFromContext(ctx, c.name); st != nil {
+		return st.tx.ExecContext(ctx, query, args...)
+	}
 	return c.db.Exec(ctx, query, args...)
 }
 
 func (c *clientWrapper) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	query, args, err := c.prepare(query, args)
+	if err != nil {
+		return err
+	}
+	if st := txFromContext(ctx, c.name); st != nil {
+		return st.tx.SelectContext(ctx, dest, query, args...)
+	}
 	return c.db.Select(ctx, dest, query, args...)
 }
 
-func (c *clientWrapper) TransactionContext(ctx context.Context, fn TxFunc) error {
+func (c *clientWrapper) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	query, args, err := c.prepare(query, args)
+	if err != nil {
+		return err
+	}
+	if st := txFromContext(ctx, c.name); st != nil {
+		return st.tx.GetContext(ctx, dest, query, args...)
+	}
+	return c.db.Get(ctx, dest, query, args...)
+}
+
+func (c *clientWrapper) NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error) {
+	query, args, err := bindNamed(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return c.ExecContext(ctx, query, args...)
+}
+
+func (c *clientWrapper) NamedSelectContext(ctx context.Context, dest any, query string, arg any) error {
+	query, args, err := bindNamed(query, arg)
+	if err != nil {
+		return err
+	}
+	return c.SelectContext(ctx, dest, query, args...)
+}
+
+func (c *clientWrapper) TransactionContext(ctx context.Context, fn TxFunc, opts ...TxOption) error {
+	if st := txFromContext(ctx, c.name); st != nil {
+		return st.savepoint(ctx, fn)
+	}
+	txOpts := make([]mysql.TxOption, 0, len(opts))
+	for _, o := range opts {
+		if o != nil {
+			txOpts = append(txOpts, mysql.TxOption(o))
+		}
+	}
 	return c.db.Transactionx(ctx, func(tx *sqlx.Tx) error {
-		return fn(ctx, tx)
-	})
+		return callTx(withTx(ctx, c.name, tx), tx, fn)
+	}, txOpts...)
 }
 
 func (c *clientWrapper) QueryContext(ctx context.Context, next mysql.NextFunc, query string, args ...any) error {
+	query, args, err := c.prepare(query, args)
+	if err != nil {
+		return err
+	}
+	if st := txFromContext(ctx, c.name); st != nil {
+		return queryRows(ctx, st.tx, next, query, args...)
+	}
 	return c.db.Query(ctx, next, query, args...)
 }
 
@@ -62,28 +138,95 @@ type SqlxWrapper struct {
 
 var _ Client = (*SqlxWrapper)(nil)
 
+// prepare 展开切片参数, 并转换为驱动对应的占位符
+func (db *SqlxWrapper) prepare(query string, args []any) (string, []any, error) {
+	return prepare(query, args, sqlx.BindType(db.DriverName()))
+}
+
 func (db *SqlxWrapper) QueryContext(ctx context.Context, next mysql.NextFunc, query string, args ...any) error {
-	rows, err := db.DB.QueryContext(ctx, query, args...)
+	query, args, err := db.prepare(query, args)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
+	return queryRows(ctx, db.queryer(ctx), next, query, args...)
+}
 
-	for rows.Next() {
-		if err := next(rows); err != nil {
-			return err
-		}
+func (db *SqlxWrapper) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	query, args, err := db.prepare(query, args)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return db.queryer(ctx).ExecContext(ctx, query, args...)
+}
+
+func (db *SqlxWrapper) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	query, args, err := db.prepare(query, args)
+	if err != nil {
+		return err
+	}
+	return sqlx.SelectContext(ctx, db.queryer(ctx), dest, query, args...)
+}
+
+func (db *SqlxWrapper) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	query, args, err := db.prepare(query, args)
+	if err != nil {
+		return err
+	}
+	return sqlx.GetContext(ctx, db.queryer(ctx), dest, query, args...)
+}
+
+func (db *SqlxWrapper) NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error) {
+	query, args, err := bindNamed(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return db.ExecContext(ctx, query, args...)
+}
+
+func (db *SqlxWrapper) NamedSelectContext(ctx context.Context, dest any, query string, arg any) error {
+	query, args, err := bindNamed(query, arg)
+	if err != nil {
+		return err
+	}
+	return db.SelectContext(ctx, dest, query, args...)
 }
 
-func (db *SqlxWrapper) TransactionContext(ctx context.Context, fn TxFunc) error {
-	tx := db.DB.MustBegin().Unsafe()
+// queryer 返回 ctx 中的事务, 不在事务中时返回 db 本身
+func (db *SqlxWrapper) queryer(ctx context.Context) queryer {
+	if st := txFromContext(ctx, db.DB); st != nil {
+		return st.tx
+	}
+	return db.DB
+}
 
-	if err := fn(ctx, tx); err != nil {
-		_ = tx.Rollback()
+func (db *SqlxWrapper) TransactionContext(ctx context.Context, fn TxFunc, opts ...TxOption) error {
+	if st := txFromContext(ctx, db.DB); st != nil {
+		return st.savepoint(ctx, fn)
+	}
+	tx, err := db.DB.BeginTxx(ctx, mergeTxOptions(opts))
+	if err != nil {
+		return fmt.Errorf("begin transaction error: %w", err)
+	}
+	tx = tx.Unsafe()
+
+	if err := callTx(withTx(ctx, db.DB, tx), tx, fn); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("rollback error: %w", rbErr))
+		}
 		return err
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction error: %w", err)
+	}
+	return nil
+}
+
+// bindNamed 将 ":name" 形式的命名参数转换为 "?" 形式的位置参数, 切片参数由各 Client 方法展开
+func bindNamed(query string, arg any) (string, []any, error) {
+	q, args, err := sqlx.Named(query, arg)
+	if err != nil {
+		return "", nil, fmt.Errorf("bind named query error: %w", err)
+	}
+	return q, args, nil
 }
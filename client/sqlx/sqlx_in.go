@@ -0,0 +1,161 @@
+package sqlx
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// prepare 展开 "IN (?)" 中的切片参数, 并将 "?" 转换为 bindType 对应的占位符。clientWrapper 和
+// SqlxWrapper 的所有方法都经过这一步, 保证两者的行为一致。
+func prepare(query string, args []any, bindType int) (string, []any, error) {
+	query, args, err := expandIn(query, args)
+	if err != nil {
+		return "", nil, err
+	}
+	return sqlx.Rebind(bindType, query), args, nil
+}
+
+// expandIn 将 "IN (?)" 对应的切片参数展开为 "IN (?, ?, ?)"。只有 "IN (?)" 中的参数会被展开,
+// 其他位置的切片参数 (如 []byte、json.RawMessage) 以及实现了 driver.Valuer 的参数原样传入。
+// 引号中的 "?" 不视为占位符。
+func expandIn(query string, args []any) (string, []any, error) {
+	if !hasSliceArg(args) {
+		return query, args, nil
+	}
+
+	b := strings.Builder{}
+	b.Grow(len(query) + 2*len(args))
+	res := make([]any, 0, len(args))
+	argIdx := 0
+
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch ch {
+		case '\'', '"', '`':
+			end := skipQuoted(query, i)
+			b.WriteString(query[i:end])
+			i = end - 1
+			continue
+		case '?':
+		default:
+			b.WriteByte(ch)
+			continue
+		}
+
+		if argIdx >= len(args) {
+			// 参数数量不足, 交给数据库报错
+			b.WriteByte('?')
+			continue
+		}
+		arg := args[argIdx]
+		argIdx++
+
+		v, ok := inSlice(arg)
+		if !ok || !isInPlaceholder(query, i) {
+			b.WriteByte('?')
+			res = append(res, arg)
+			continue
+		}
+		if v.Len() == 0 {
+			return "", nil, errors.New("expand IN clause error: empty slice passed to 'IN (?)'")
+		}
+		for j := 0; j < v.Len(); j++ {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteByte('?')
+			res = append(res, v.Index(j).Interface())
+		}
+	}
+
+	res = append(res, args[argIdx:]...)
+	return b.String(), res, nil
+}
+
+func hasSliceArg(args []any) bool {
+	for _, a := range args {
+		if _, ok := inSlice(a); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// inSlice 判断 arg 是否为可以在 "IN (?)" 中展开的切片或数组, 元素为 byte 的类型不展开
+func inSlice(arg any) (reflect.Value, bool) {
+	if arg == nil {
+		return reflect.Value{}, false
+	}
+	if _, ok := arg.(driver.Valuer); ok {
+		return reflect.Value{}, false
+	}
+	v := reflect.ValueOf(arg)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	if k := v.Kind(); k != reflect.Slice && k != reflect.Array {
+		return reflect.Value{}, false
+	}
+	if v.Type().Elem().Kind() == reflect.Uint8 {
+		return reflect.Value{}, false
+	}
+	return v, true
+}
+
+// isInPlaceholder 判断 query[i] 处的 "?" 是否为 "IN (?)" 中唯一的占位符, 不区分大小写
+func isInPlaceholder(query string, i int) bool {
+	j := i + 1
+	for j < len(query) && isSpace(query[j]) {
+		j++
+	}
+	if j >= len(query) || query[j] != ')' {
+		return false
+	}
+
+	j = i - 1
+	for j >= 0 && isSpace(query[j]) {
+		j--
+	}
+	if j < 0 || query[j] != '(' {
+		return false
+	}
+	j--
+	for j >= 0 && isSpace(query[j]) {
+		j--
+	}
+	if j < 1 || !strings.EqualFold(query[j-1:j+1], "in") {
+		return false
+	}
+	return j < 2 || !isIdentChar(query[j-2])
+}
+
+// skipQuoted 返回从 query[start] 开始的引号字面量之后的位置, 支持反斜杠转义和连续两个引号
+func skipQuoted(query string, start int) int {
+	quote := query[start]
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(query)
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
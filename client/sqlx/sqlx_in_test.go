@@ -0,0 +1,133 @@
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/smartystreets/goconvey/convey"
+	"trpc.group/trpc-go/trpc-database/mysql"
+)
+
+var (
+	cv = convey.Convey
+	so = convey.So
+	eq = convey.ShouldEqual
+
+	isNil  = convey.ShouldBeNil
+	notNil = convey.ShouldNotBeNil
+	resem  = convey.ShouldResemble
+)
+
+func TestExpandIn(t *testing.T) {
+	raw := json.RawMessage(`{"a":1}`)
+
+	cv("只展开 IN (?) 中的切片", t, func() {
+		cases := []struct {
+			query     string
+			args      []any
+			wantQuery string
+			wantArgs  []any
+		}{{
+			query:     "SELECT * FROM t WHERE id IN (?)",
+			args:      []any{[]int64{1, 2, 3}},
+			wantQuery: "SELECT * FROM t WHERE id IN (?, ?, ?)",
+			wantArgs:  []any{int64(1), int64(2), int64(3)},
+		}, {
+			query:     "select * from t where a = ? and id not in ( ? ) and b = ?",
+			args:      []any{1, []string{"x", "y"}, 2},
+			wantQuery: "select * from t where a = ? and id not in ( ?, ? ) and b = ?",
+			wantArgs:  []any{1, "x", "y", 2},
+		}, {
+			query:     "INSERT INTO t (id, ext) VALUES (?, ?)",
+			args:      []any{1, raw},
+			wantQuery: "INSERT INTO t (id, ext) VALUES (?, ?)",
+			wantArgs:  []any{1, raw},
+		}, {
+			query:     "UPDATE t SET tags = ? WHERE id IN (?)",
+			args:      []any{[]string{"x"}, []int{1, 2}},
+			wantQuery: "UPDATE t SET tags = ? WHERE id IN (?, ?)",
+			wantArgs:  []any{[]string{"x"}, 1, 2},
+		}, {
+			query:     "SELECT * FROM t WHERE data IN (?)",
+			args:      []any{[]byte("abc")},
+			wantQuery: "SELECT * FROM t WHERE data IN (?)",
+			wantArgs:  []any{[]byte("abc")},
+		}, {
+			query:     "SELECT * FROM t WHERE name = 'IN (?)' AND id IN (?)",
+			args:      []any{[]int{1, 2}},
+			wantQuery: "SELECT * FROM t WHERE name = 'IN (?)' AND id IN (?, ?)",
+			wantArgs:  []any{1, 2},
+		}, {
+			query:     "SELECT * FROM t WHERE id IN (?, ?)",
+			args:      []any{1, 2},
+			wantQuery: "SELECT * FROM t WHERE id IN (?, ?)",
+			wantArgs:  []any{1, 2},
+		}, {
+			query:     "SELECT * FROM t WHERE min(?)",
+			args:      []any{[]int{1, 2}},
+			wantQuery: "SELECT * FROM t WHERE min(?)",
+			wantArgs:  []any{[]int{1, 2}},
+		}}
+
+		for _, c := range cases {
+			q, args, err := expandIn(c.query, c.args)
+			so(err, isNil)
+			so(q, eq, c.wantQuery)
+			so(args, resem, c.wantArgs)
+		}
+	})
+
+	cv("空切片返回错误", t, func() {
+		_, _, err := expandIn("SELECT * FROM t WHERE id IN (?)", []any{[]int{}})
+		so(err, notNil)
+	})
+
+	cv("转换占位符", t, func() {
+		q, args, err := prepare("SELECT * FROM t WHERE a = ? AND id IN (?)", []any{1, []int{2, 3}}, sqlx.DOLLAR)
+		so(err, isNil)
+		so(q, eq, "SELECT * FROM t WHERE a = $1 AND id IN ($2, $3)")
+		so(args, resem, []any{1, 2, 3})
+	})
+}
+
+// fakeMySQL 记录 clientWrapper 最终传给 trpc mysql 的语句
+type fakeMySQL struct {
+	mysql.Client
+	query string
+	args  []any
+}
+
+func (f *fakeMySQL) Exec(_ context.Context, query string, args ...any) (sql.Result, error) {
+	f.query, f.args = query, args
+	return nil, nil
+}
+
+func (f *fakeMySQL) Select(_ context.Context, _ any, query string, args ...any) error {
+	f.query, f.args = query, args
+	return nil
+}
+
+func TestClientWrapperExpand(t *testing.T) {
+	cv("clientWrapper 与 SqlxWrapper 的展开规则一致", t, func() {
+		f := &fakeMySQL{}
+		c := &clientWrapper{name: "test", db: f}
+		ctx := context.Background()
+		raw := json.RawMessage(`[1,2]`)
+
+		_, err := c.ExecContext(ctx, "UPDATE t SET ext = ? WHERE id IN (?)", raw, []int64{1, 2})
+		so(err, isNil)
+		so(f.query, eq, "UPDATE t SET ext = ? WHERE id IN (?, ?)")
+		so(f.args, resem, []any{raw, int64(1), int64(2)})
+
+		type arg struct {
+			IDs []int64 `db:"ids"`
+		}
+		err = c.NamedSelectContext(ctx, nil, "SELECT * FROM t WHERE id IN (:ids)", arg{IDs: []int64{3, 4}})
+		so(err, isNil)
+		so(f.query, eq, "SELECT * FROM t WHERE id IN (?, ?)")
+		so(f.args, resem, []any{int64(3), int64(4)})
+	})
+}
@@ -0,0 +1,281 @@
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Andrew-M-C/trpc-go-utils/log"
+	"github.com/Andrew-M-C/trpc-go-utils/metrics"
+	"trpc.group/trpc-go/trpc-database/mysql"
+)
+
+// Instrument 包装 Client, 统计每一条语句的耗时和错误并通过 metrics 上报, 耗时超过阈值的语句
+// 以结构化日志打印。
+//
+// metrics 按照操作类型 (query、exec、select、get、transaction) 上报; 同时以语句指纹区分每一条
+// 语句, 指纹为归一化之后的 SQL (字面量和 "IN (?, ?)" 中的参数都替换为一个 "?", 去掉注释) 的
+// 8 位十六进制哈希, 使得 metrics 的数量只与代码中的语句数量有关。慢查询日志中同时打印指纹和
+// 归一化之后的 SQL, 以便对照。参见 WithMetricsPrefix。
+//
+// 在 TransactionContext 的回调中, 需要使用 Instrument 返回的 Client 执行语句才会被统计。
+func Instrument(c Client, opts ...InstrumentOption) Client {
+	return &instrumented{
+		Client: c,
+		opts:   mergeInstrumentOptions(opts),
+	}
+}
+
+type instrumented struct {
+	Client
+	opts *instrumentOptions
+}
+
+func (c *instrumented) QueryContext(ctx context.Context, next mysql.NextFunc, query string, args ...any) error {
+	start := time.Now()
+	rows := 0
+	err := c.Client.QueryContext(ctx, func(r *sql.Rows) error {
+		rows++
+		return next(r)
+	}, query, args...)
+	c.record(ctx, "query", query, len(args), int64(rows), start, err)
+	return err
+}
+
+func (c *instrumented) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	res, err := c.Client.ExecContext(ctx, query, args...)
+	rows := int64(0)
+	if err == nil {
+		rows, _ = res.RowsAffected()
+	}
+	c.record(ctx, "exec", query, len(args), rows, start, err)
+	return res, err
+}
+
+func (c *instrumented) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	start := time.Now()
+	err := c.Client.SelectContext(ctx, dest, query, args...)
+	c.record(ctx, "select", query, len(args), sliceLen(dest), start, err)
+	return err
+}
+
+func (c *instrumented) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	start := time.Now()
+	err := c.Client.GetContext(ctx, dest, query, args...)
+	rows := int64(1)
+	if err != nil {
+		rows = 0
+	}
+	c.record(ctx, "get", query, len(args), rows, start, err)
+	return err
+}
+
+func (c *instrumented) NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error) {
+	query, args, err := bindNamed(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return c.ExecContext(ctx, query, args...)
+}
+
+func (c *instrumented) NamedSelectContext(ctx context.Context, dest any, query string, arg any) error {
+	query, args, err := bindNamed(query, arg)
+	if err != nil {
+		return err
+	}
+	return c.SelectContext(ctx, dest, query, args...)
+}
+
+func (c *instrumented) TransactionContext(ctx context.Context, fn TxFunc, opts ...TxOption) error {
+	start := time.Now()
+	err := c.Client.TransactionContext(ctx, fn, opts...)
+	c.record(ctx, "transaction", "TRANSACTION", 0, 0, start, err)
+	return err
+}
+
+// record 上报一条语句的 metrics, 并在超过阈值时打印日志
+func (c *instrumented) record(
+	ctx context.Context, op, query string, argCount int, rows int64, start time.Time, err error,
+) {
+	ela := time.Since(start)
+	stmt := normalizeSQL(query)
+	fp := fingerprint(stmt)
+	if len(stmt) > maxNormalizedSQLLen {
+		stmt = stmt[:maxNormalizedSQLLen]
+	}
+
+	prefix := c.opts.metricsPrefix + op + "."
+	for _, p := range []string{prefix, prefix + "stmt." + fp + "."} {
+		metrics.IncrCounter(p+"count", 1)
+		metrics.IncrCounter(p+"elapseMsSum", ela.Milliseconds())
+		if err != nil {
+			metrics.IncrCounter(p+"fail", 1)
+		}
+	}
+
+	if c.opts.slowThreshold <= 0 || ela < c.opts.slowThreshold {
+		return
+	}
+	l := log.New().
+		Text("slow sql").
+		With("OP", op).
+		With("FINGERPRINT", fp).
+		With("SQL", stmt).
+		With("ARG_COUNT", argCount).
+		With("ROWS", rows).
+		With("ELAPSE_MS", ela.Milliseconds())
+	if err != nil {
+		l = l.Err(err)
+	}
+	l.WarnContext(ctx)
+}
+
+// sliceLen 返回 SelectContext 的 dest 中的元素数量
+func sliceLen(dest any) int64 {
+	v := reflect.ValueOf(dest)
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		return 0
+	}
+	return int64(v.Len())
+}
+
+// 归一化之后的 SQL 的最大长度, 避免日志过长
+const maxNormalizedSQLLen = 256
+
+// fingerprint 返回归一化之后的 SQL 的 8 位十六进制哈希
+func fingerprint(stmt string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(stmt))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// normalizeSQL 将字符串和数字字面量替换为 "?", 去掉注释, 合并连续的空白字符, 并将
+// "IN (?, ?, ...)" 合并为 "IN (?)", 使得参数不同的同一条语句得到相同的结果。
+func normalizeSQL(query string) string {
+	b := strings.Builder{}
+	b.Grow(len(query))
+
+	lastSpace := true // 去掉开头的空白字符
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case commentEnd(query, i) > i:
+			// 注释视为空白字符
+			i = commentEnd(query, i) - 1
+			if !lastSpace {
+				b.WriteByte(' ')
+				lastSpace = true
+			}
+			continue
+
+		case isSpace(ch):
+			if !lastSpace {
+				b.WriteByte(' ')
+				lastSpace = true
+			}
+			continue
+
+		case ch == '\'' || ch == '"':
+			// 跳过字符串字面量, 包括转义和连续两个引号的情况
+			for i++; i < len(query); i++ {
+				if query[i] == '\\' {
+					i++
+					continue
+				}
+				if query[i] == ch {
+					if i+1 < len(query) && query[i+1] == ch {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			b.WriteByte('?')
+
+		case isDigit(ch) && (i == 0 || !isIdentChar(query[i-1])):
+			for i+1 < len(query) && (isDigit(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			b.WriteByte('?')
+
+		default:
+			b.WriteByte(ch)
+		}
+		lastSpace = false
+	}
+
+	s := strings.TrimRight(b.String(), " ")
+	return collapsePlaceholders(s)
+}
+
+// collapsePlaceholders 将 "(?, ?, ?)" 合并为 "(?)"
+func collapsePlaceholders(s string) string {
+	if strings.Count(s, "?") < 2 {
+		return s
+	}
+	b := strings.Builder{}
+	b.Grow(len(s))
+
+	for i := 0; i < len(s); i++ {
+		b.WriteByte(s[i])
+		if s[i] != '?' {
+			continue
+		}
+		// 跳过后续的 ", ?"
+		for j := i + 1; ; {
+			for j < len(s) && s[j] == ' ' {
+				j++
+			}
+			if j >= len(s) || s[j] != ',' {
+				break
+			}
+			j++
+			for j < len(s) && s[j] == ' ' {
+				j++
+			}
+			if j >= len(s) || s[j] != '?' {
+				break
+			}
+			i = j
+			j++
+		}
+	}
+	return b.String()
+}
+
+// commentEnd 返回从 query[i] 开始的注释之后的位置, 支持 "-- "、"#" 和 "/* */" 三种形式。
+// query[i] 不是注释的开头时返回 i。
+func commentEnd(query string, i int) int {
+	rest := query[i:]
+	switch {
+	case strings.HasPrefix(rest, "/*"):
+		if end := strings.Index(rest[2:], "*/"); end >= 0 {
+			return i + 2 + end + 2
+		}
+		return len(query)
+	case rest[0] == '#' || (strings.HasPrefix(rest, "--") && (len(rest) == 2 || isSpace(rest[2]))):
+		if end := strings.IndexByte(rest, '\n'); end >= 0 {
+			return i + end
+		}
+		return len(query)
+	default:
+		return i
+	}
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isIdentChar(ch byte) bool {
+	return ch == '_' || ch == '$' || ch == '.' || isDigit(ch) ||
+		(ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
@@ -0,0 +1,65 @@
+package sqlx
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeSQL(t *testing.T) {
+	cv("归一化 SQL", t, func() {
+		cases := []struct {
+			query string
+			want  string
+		}{
+			{"  SELECT *\n\tFROM t_user   WHERE id = 1  ", "SELECT * FROM t_user WHERE id = ?"},
+			{"SELECT * FROM t WHERE name = 'Alice' AND age > 18.5", "SELECT * FROM t WHERE name = ? AND age > ?"},
+			{`SELECT * FROM t WHERE name = "Bob"`, "SELECT * FROM t WHERE name = ?"},
+			{`SELECT * FROM t WHERE name = 'it\'s' AND id = 2`, "SELECT * FROM t WHERE name = ? AND id = ?"},
+			{`SELECT * FROM t WHERE name = 'it''s' AND id = 2`, "SELECT * FROM t WHERE name = ? AND id = ?"},
+			{`SELECT * FROM t WHERE name = "say \"hi\"" AND id = 2`, "SELECT * FROM t WHERE name = ? AND id = ?"},
+			{"SELECT * FROM t WHERE name = '1, 2, (3)'", "SELECT * FROM t WHERE name = ?"},
+			{"SELECT * FROM t2 WHERE col1 = ?", "SELECT * FROM t2 WHERE col1 = ?"},
+			{"SELECT * FROM t WHERE id IN (?, ?, ?)", "SELECT * FROM t WHERE id IN (?)"},
+			{"SELECT * FROM t WHERE id IN (?,?,?) AND b IN ( ? , ? )", "SELECT * FROM t WHERE id IN (?) AND b IN ( ? )"},
+			{"SELECT * FROM t WHERE id IN (1, 2, 3)", "SELECT * FROM t WHERE id IN (?)"},
+			{"INSERT INTO t (a, b) VALUES (?, ?), (?, ?)", "INSERT INTO t (a, b) VALUES (?), (?)"},
+			{"SELECT /* hint */ * FROM t -- trailing\nWHERE id = 1", "SELECT * FROM t WHERE id = ?"},
+			{"SELECT * FROM t # mysql comment\nWHERE id = 1", "SELECT * FROM t WHERE id = ?"},
+			{"SELECT a--b FROM t", "SELECT a--b FROM t"},
+			{"SELECT * FROM t /* unterminated", "SELECT * FROM t"},
+		}
+		for _, c := range cases {
+			so(normalizeSQL(c.query), eq, c.want)
+		}
+	})
+
+	cv("合并占位符", t, func() {
+		cases := []struct {
+			s    string
+			want string
+		}{
+			{"(?)", "(?)"},
+			{"(?, ?, ?)", "(?)"},
+			{"(?,?)", "(?)"},
+			{"(?, a, ?)", "(?, a, ?)"},
+			{"? , ?", "?"},
+			{"?,", "?,"},
+			{"a, b", "a, b"},
+		}
+		for _, c := range cases {
+			so(collapsePlaceholders(c.s), eq, c.want)
+		}
+	})
+
+	cv("不同参数的同一条语句指纹相同", t, func() {
+		a := fingerprint(normalizeSQL("SELECT * FROM t WHERE id IN (1, 2) AND name = 'a'"))
+		b := fingerprint(normalizeSQL("SELECT * FROM t WHERE id = 1"))
+		c := fingerprint(normalizeSQL("SELECT * FROM t WHERE id IN (3) AND name = 'bcd'"))
+		so(len(a), eq, 8)
+		so(a, eq, c)
+		so(a == b, eq, false)
+
+		long := "SELECT " + strings.Repeat("a, ", 200) + "b FROM t"
+		so(fingerprint(normalizeSQL(long)) == fingerprint(normalizeSQL(long+" WHERE id = 1")), eq, false)
+	})
+}
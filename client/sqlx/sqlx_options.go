@@ -0,0 +1,74 @@
+package sqlx
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// TxOption 表示 TransactionContext 的额外参数
+type TxOption func(*sql.TxOptions)
+
+// WithIsolationLevel 指定事务的隔离级别, 默认使用数据库的默认隔离级别
+func WithIsolationLevel(level sql.IsolationLevel) TxOption {
+	return func(o *sql.TxOptions) {
+		o.Isolation = level
+	}
+}
+
+// WithReadOnly 指定事务为只读事务
+func WithReadOnly() TxOption {
+	return func(o *sql.TxOptions) {
+		o.ReadOnly = true
+	}
+}
+
+func mergeTxOptions(opts []TxOption) *sql.TxOptions {
+	o := &sql.TxOptions{}
+	for _, f := range opts {
+		if f != nil {
+			f(o)
+		}
+	}
+	return o
+}
+
+// InstrumentOption 表示 Instrument 的额外参数
+type InstrumentOption func(*instrumentOptions)
+
+type instrumentOptions struct {
+	slowThreshold time.Duration
+	metricsPrefix string
+}
+
+// WithSlowThreshold 指定慢查询日志的阈值, 默认 500ms。小于等于零时不打印慢查询日志
+func WithSlowThreshold(d time.Duration) InstrumentOption {
+	return func(o *instrumentOptions) {
+		o.slowThreshold = d
+	}
+}
+
+// WithMetricsPrefix 指定 metrics 的前缀, 默认为 "amc.utils.sqlx."。每种操作和每条语句上报
+// count (执行次数)、elapseMsSum (耗时之和, 单位毫秒) 和 fail (失败次数) 三个计数器, 平均耗时为
+// 同一周期内 elapseMsSum 与 count 的增量之比。
+func WithMetricsPrefix(prefix string) InstrumentOption {
+	return func(o *instrumentOptions) {
+		if prefix != "" && !strings.HasSuffix(prefix, ".") {
+			prefix += "."
+		}
+		o.metricsPrefix = prefix
+	}
+}
+
+func mergeInstrumentOptions(opts []InstrumentOption) *instrumentOptions {
+	o := &instrumentOptions{
+		slowThreshold: 500 * time.Millisecond,
+		metricsPrefix: "amc.utils.sqlx.",
+	}
+	for _, f := range opts {
+		if f != nil {
+			f(o)
+		}
+	}
+	return o
+}
@@ -0,0 +1,110 @@
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+
+	"github.com/jmoiron/sqlx"
+	"trpc.group/trpc-go/trpc-database/mysql"
+	"trpc.group/trpc-go/trpc-go/client"
+)
+
+// ClientGetterRW 与 ClientGetter 相同, 但是读写分离: SelectContext、GetContext、QueryContext 及
+// NamedSelectContext 轮流发往 readers 中的从库, 其余方法发往 writer 主库。readers 为空时全部发往
+// 主库。
+//
+// 以下情况下读操作也会发往主库:
+//   - ctx 经过 WithPrimary 处理, 一般用于写入之后需要立即读到最新数据的场景
+//   - ctx 为主库 TransactionContext 回调中的 ctx
+func ClientGetterRW(
+	writer string, readers []string, opts ...client.Option,
+) func(context.Context) (Client, error) {
+	// trpc mysql 的 client 本身是动态的, 因此只需要创建一次
+	w := &clientWrapper{name: writer, db: mysql.NewUnsafeClient(writer, opts...)}
+	rs := make([]Client, 0, len(readers))
+	for _, name := range readers {
+		rs = append(rs, &clientWrapper{name: name, db: mysql.NewUnsafeClient(name, opts...)})
+	}
+	c := NewRWClient(w, rs...)
+	return func(context.Context) (Client, error) {
+		return c, nil
+	}
+}
+
+// NewRWClient 按照与 ClientGetterRW 相同的规则, 将读写操作分别发往 writer 和 readers
+func NewRWClient(writer Client, readers ...Client) Client {
+	return &rwClient{
+		writer:  writer,
+		readers: readers,
+	}
+}
+
+type primaryKey struct{}
+
+// WithPrimary 返回一个新的 ctx, 使用 ClientGetterRW 返回的 Client 时, 读操作也发往主库
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func isPrimary(ctx context.Context) bool {
+	b, _ := ctx.Value(primaryKey{}).(bool)
+	return b
+}
+
+type rwClient struct {
+	writer  Client
+	readers []Client
+	counter atomic.Uint64 // 轮询从库的计数
+}
+
+// rwTxKey 标记 ctx 处于 rwClient 主库的事务中
+type rwTxKey struct {
+	c *rwClient
+}
+
+// reader 返回读操作应当使用的 client
+func (c *rwClient) reader(ctx context.Context) Client {
+	if len(c.readers) == 0 || isPrimary(ctx) || c.InTransaction(ctx) {
+		return c.writer
+	}
+	return c.readers[c.counter.Add(1)%uint64(len(c.readers))]
+}
+
+// InTransaction 实现 TxDetector
+func (c *rwClient) InTransaction(ctx context.Context) bool {
+	if ctx.Value(rwTxKey{c: c}) != nil {
+		return true
+	}
+	return InTransaction(ctx, c.writer)
+}
+
+func (c *rwClient) QueryContext(ctx context.Context, next mysql.NextFunc, query string, args ...any) error {
+	return c.reader(ctx).QueryContext(ctx, next, query, args...)
+}
+
+func (c *rwClient) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	return c.reader(ctx).SelectContext(ctx, dest, query, args...)
+}
+
+func (c *rwClient) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	return c.reader(ctx).GetContext(ctx, dest, query, args...)
+}
+
+func (c *rwClient) NamedSelectContext(ctx context.Context, dest any, query string, arg any) error {
+	return c.reader(ctx).NamedSelectContext(ctx, dest, query, arg)
+}
+
+func (c *rwClient) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return c.writer.ExecContext(ctx, query, args...)
+}
+
+func (c *rwClient) NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error) {
+	return c.writer.NamedExecContext(ctx, query, arg)
+}
+
+func (c *rwClient) TransactionContext(ctx context.Context, fn TxFunc, opts ...TxOption) error {
+	return c.writer.TransactionContext(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		return fn(context.WithValue(ctx, rwTxKey{c: c}, true), tx)
+	}, opts...)
+}
@@ -0,0 +1,116 @@
+package sqlx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/Andrew-M-C/go.util/runtime/caller"
+	"github.com/Andrew-M-C/trpc-go-utils/recovery"
+	"github.com/jmoiron/sqlx"
+	"trpc.group/trpc-go/trpc-database/mysql"
+)
+
+// queryer 表示 *sqlx.DB 和 *sqlx.Tx 共有的方法
+type queryer interface {
+	sqlx.QueryerContext
+	sqlx.ExecerContext
+}
+
+// txKey 是事务在 context 中的 key。owner 区分不同的数据库, clientWrapper 使用 client 名称,
+// SqlxWrapper 使用 *sqlx.DB 指针。
+type txKey struct {
+	owner any
+}
+
+// txState 表示 context 中正在进行的事务
+type txState struct {
+	tx         *sqlx.Tx
+	savepoints atomic.Int64
+}
+
+func withTx(ctx context.Context, owner any, tx *sqlx.Tx) context.Context {
+	return context.WithValue(ctx, txKey{owner: owner}, &txState{tx: tx})
+}
+
+func txFromContext(ctx context.Context, owner any) *txState {
+	st, _ := ctx.Value(txKey{owner: owner}).(*txState)
+	return st
+}
+
+// TxDetector 由可以判断 ctx 是否处于自身事务中的 Client 实现。包装其他 Client 的实现 (比如
+// Instrument 的返回值) 应当实现这个接口并通过 InTransaction 转发给被包装的 Client, 否则
+// NewRWClient 等无法识别事务, 事务中的读操作会被发往从库。
+type TxDetector interface {
+	InTransaction(ctx context.Context) bool
+}
+
+// InTransaction 判断 ctx 是否处于 c 的事务中, 即是否为 c.TransactionContext 回调传入的 ctx。
+// c 未实现 TxDetector 时返回 false。
+func InTransaction(ctx context.Context, c Client) bool {
+	d, ok := c.(TxDetector)
+	return ok && d.InTransaction(ctx)
+}
+
+// InTransaction 实现 TxDetector
+func (c *clientWrapper) InTransaction(ctx context.Context) bool {
+	return txFromContext(ctx, c.name) != nil
+}
+
+// InTransaction 实现 TxDetector
+func (db *SqlxWrapper) InTransaction(ctx context.Context) bool {
+	return txFromContext(ctx, db.DB) != nil
+}
+
+// InTransaction 实现 TxDetector
+func (c *instrumented) InTransaction(ctx context.Context) bool {
+	return InTransaction(ctx, c.Client)
+}
+
+// savepoint 在已有的事务中以 SAVEPOINT 执行嵌套的事务, fn 返回错误时只回滚到 SAVEPOINT
+func (st *txState) savepoint(ctx context.Context, fn TxFunc) error {
+	name := fmt.Sprintf("amc_sp_%d", st.savepoints.Add(1))
+	if _, err := st.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("create savepoint error: %w", err)
+	}
+
+	if err := callTx(ctx, st.tx, fn); err != nil {
+		if _, rbErr := st.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("rollback to savepoint error: %w", rbErr))
+		}
+		return err
+	}
+
+	if _, err := st.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("release savepoint error: %w", err)
+	}
+	return nil
+}
+
+// callTx 执行 fn, 并将 fn 中的 panic 转为错误返回, 使得调用方可以回滚事务
+func callTx(ctx context.Context, tx *sqlx.Tx, fn TxFunc) (err error) {
+	defer recovery.CatchPanic(
+		recovery.WithContext(ctx),
+		recovery.WithErrorLog(),
+		recovery.WithCallback(func(_ context.Context, info any, _ []caller.Caller) {
+			err = fmt.Errorf("panic in transaction: %v", info)
+		}),
+	)
+	return fn(ctx, tx)
+}
+
+func queryRows(ctx context.Context, q queryer, next mysql.NextFunc, query string, args ...any) error {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := next(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
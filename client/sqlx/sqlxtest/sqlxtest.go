@@ -0,0 +1,125 @@
+// Package sqlxtest 提供基于内存 SQLite 的 sqlx.Client, 用于在没有 MySQL 的环境下进行单元测试
+package sqlxtest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	amcsqlx "github.com/Andrew-M-C/trpc-go-utils/client/sqlx"
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite" // 纯 Go 实现的 SQLite 驱动
+)
+
+// DB 是基于内存 SQLite 数据库的 sqlx.Client。
+//
+// 每个 DB 使用一个独立命名的共享缓存内存数据库, 连接池中的所有连接访问同一份数据, 事务之外的
+// 读操作不需要等待事务占用的连接。在 TransactionContext 的回调中, 应当使用回调传入的 ctx 调用
+// DB 的方法; 不使用事务的 ctx 写入, 或者读取事务已经写入的表时, 会等待事务结束。
+type DB struct {
+	amcsqlx.Client
+	raw  *sqlx.DB
+	keep *sql.Conn // 内存数据库在最后一个连接关闭时被销毁, 因此始终保留一个连接
+	rec  *recorder
+}
+
+// dbSeq 用于生成进程内唯一的内存数据库名称
+var dbSeq atomic.Int64
+
+// New 新建一个内存 SQLite 数据库
+func New(opts ...Option) (*DB, error) {
+	o := mergeOptions(opts)
+
+	dsn := fmt.Sprintf("file:sqlxtest_%d_%d?mode=memory&cache=shared", os.Getpid(), dbSeq.Add(1))
+	raw, err := sqlx.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite error: %w", err)
+	}
+	keep, err := raw.Conn(context.Background())
+	if err != nil {
+		_ = raw.Close()
+		return nil, fmt.Errorf("connect sqlite error: %w", err)
+	}
+
+	db := &DB{
+		raw:  raw,
+		keep: keep,
+	}
+	if o.schemaDir != "" {
+		if err := applySchemaDir(raw, o.schemaDir); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+
+	var cli amcsqlx.Client = &amcsqlx.SqlxWrapper{DB: raw}
+	if o.record {
+		db.rec = &recorder{Client: cli}
+		cli = db.rec
+	}
+	db.Client = cli
+	return db, nil
+}
+
+// MustNew 与 New 相同, 但是出错时调用 t.Fatal, 并在测试结束时自动关闭数据库
+func MustNew(t testing.TB, opts ...Option) *DB {
+	t.Helper()
+	db, err := New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+// Close 关闭数据库, 数据库中的所有数据都会被丢弃
+func (db *DB) Close() error {
+	_ = db.keep.Close()
+	return db.raw.Close()
+}
+
+// InTransaction 实现 sqlx.TxDetector
+func (db *DB) InTransaction(ctx context.Context) bool {
+	return amcsqlx.InTransaction(ctx, db.Client)
+}
+
+// Raw 返回底层的 sqlx.DB, 一般用于准备测试数据。通过 Raw 执行的语句不会被记录。
+func (db *DB) Raw() *sqlx.DB {
+	return db.raw
+}
+
+// applySchemaDir 按照文件名顺序执行 dir 中的所有 .sql 文件
+func applySchemaDir(db *sqlx.DB, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("read schema dir error: %w", err)
+	}
+
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".sql") {
+			continue
+		}
+		files = append(files, e.Name())
+	}
+	slices.Sort(files)
+
+	for _, name := range files {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("read schema file '%s' error: %w", name, err)
+		}
+		if _, err := db.Exec(string(b)); err != nil {
+			return fmt.Errorf("apply schema file '%s' error: %w", name, err)
+		}
+	}
+	return nil
+}
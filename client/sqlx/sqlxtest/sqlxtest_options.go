@@ -0,0 +1,33 @@
+package sqlxtest
+
+// Option 表示 New 的额外参数
+type Option func(*options)
+
+type options struct {
+	schemaDir string
+	record    bool
+}
+
+// WithSchemaDir 在创建数据库之后, 按照文件名顺序执行 dir 中的所有 .sql 文件
+func WithSchemaDir(dir string) Option {
+	return func(o *options) {
+		o.schemaDir = dir
+	}
+}
+
+// WithRecording 记录通过 DB 执行的所有语句, 参见 DB.Statements 和 DB.AssertExecuted
+func WithRecording() Option {
+	return func(o *options) {
+		o.record = true
+	}
+}
+
+func mergeOptions(opts []Option) *options {
+	o := &options{}
+	for _, f := range opts {
+		if f != nil {
+			f(o)
+		}
+	}
+	return o
+}
@@ -0,0 +1,116 @@
+package sqlxtest
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	amcsqlx "github.com/Andrew-M-C/trpc-go-utils/client/sqlx"
+	"trpc.group/trpc-go/trpc-database/mysql"
+)
+
+// Statement 表示一条被记录的语句。Named 系列方法记录的 Args 只有一个元素, 即传入的 arg。
+type Statement struct {
+	Query string
+	Args  []any
+}
+
+// recorder 包装 Client 并记录执行的语句, 事务的判断转发给被包装的 Client
+type recorder struct {
+	amcsqlx.Client
+
+	lock  sync.Mutex
+	stmts []Statement
+}
+
+var _ amcsqlx.TxDetector = (*recorder)(nil)
+
+// InTransaction 实现 sqlx.TxDetector
+func (r *recorder) InTransaction(ctx context.Context) bool {
+	return amcsqlx.InTransaction(ctx, r.Client)
+}
+
+func (r *recorder) add(query string, args []any) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stmts = append(r.stmts, Statement{Query: query, Args: args})
+}
+
+func (r *recorder) QueryContext(ctx context.Context, next mysql.NextFunc, query string, args ...any) error {
+	r.add(query, args)
+	return r.Client.QueryContext(ctx, next, query, args...)
+}
+
+func (r *recorder) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	r.add(query, args)
+	return r.Client.ExecContext(ctx, query, args...)
+}
+
+func (r *recorder) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	r.add(query, args)
+	return r.Client.SelectContext(ctx, dest, query, args...)
+}
+
+func (r *recorder) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	r.add(query, args)
+	return r.Client.GetContext(ctx, dest, query, args...)
+}
+
+func (r *recorder) NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error) {
+	r.add(query, []any{arg})
+	return r.Client.NamedExecContext(ctx, query, arg)
+}
+
+func (r *recorder) NamedSelectContext(ctx context.Context, dest any, query string, arg any) error {
+	r.add(query, []any{arg})
+	return r.Client.NamedSelectContext(ctx, dest, query, arg)
+}
+
+// Statements 返回已经记录的语句, 没有指定 WithRecording 时返回 nil
+func (db *DB) Statements() []Statement {
+	if db.rec == nil {
+		return nil
+	}
+	db.rec.lock.Lock()
+	defer db.rec.lock.Unlock()
+	return append([]Statement(nil), db.rec.stmts...)
+}
+
+// ResetStatements 清空已经记录的语句
+func (db *DB) ResetStatements() {
+	if db.rec == nil {
+		return
+	}
+	db.rec.lock.Lock()
+	defer db.rec.lock.Unlock()
+	db.rec.stmts = nil
+}
+
+// AssertExecuted 断言 query 已经被执行过, 比较时忽略空白字符的差异。args 不为空时还会比较参数。
+// 需要指定 WithRecording。
+func (db *DB) AssertExecuted(t testing.TB, query string, args ...any) {
+	t.Helper()
+	if db.rec == nil {
+		t.Fatal("sqlxtest: AssertExecuted requires WithRecording")
+		return
+	}
+
+	want := compactSQL(query)
+	for _, st := range db.Statements() {
+		if compactSQL(st.Query) != want {
+			continue
+		}
+		if len(args) == 0 || reflect.DeepEqual(st.Args, args) {
+			return
+		}
+	}
+	t.Errorf("sqlxtest: statement not executed: %s %v", want, args)
+}
+
+// compactSQL 合并 SQL 中连续的空白字符
+func compactSQL(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
@@ -0,0 +1,287 @@
+package sqlxtest_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	amcsqlx "github.com/Andrew-M-C/trpc-go-utils/client/sqlx"
+	"github.com/Andrew-M-C/trpc-go-utils/client/sqlx/sqlxtest"
+	"github.com/jmoiron/sqlx"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+var (
+	cv = convey.Convey
+	so = convey.So
+	eq = convey.ShouldEqual
+
+	isNil  = convey.ShouldBeNil
+	notNil = convey.ShouldNotBeNil
+	resem  = convey.ShouldResemble
+)
+
+type user struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+func newDB(t *testing.T) *sqlxtest.DB {
+	dir := t.TempDir()
+	schema := "CREATE TABLE t_user (id INTEGER PRIMARY KEY, name TEXT NOT NULL);"
+	if err := os.WriteFile(filepath.Join(dir, "001_user.sql"), []byte(schema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return sqlxtest.MustNew(t, sqlxtest.WithSchemaDir(dir), sqlxtest.WithRecording())
+}
+
+func TestClient(t *testing.T) {
+	cv("基本读写", t, func() {
+		db := newDB(t)
+		ctx := context.Background()
+
+		users := []user{{1, "Alice"}, {2, "Bob"}, {3, "Carol"}}
+		_, err := db.NamedExecContext(ctx, "INSERT INTO t_user (id, name) VALUES (:id, :name)", users)
+		so(err, isNil)
+
+		var res []user
+		err = db.SelectContext(ctx, &res, "SELECT id, name FROM t_user WHERE id IN (?) ORDER BY id", []int64{1, 3})
+		so(err, isNil)
+		so(len(res), eq, 2)
+		so(res[1].Name, eq, "Carol")
+
+		u := user{}
+		err = db.GetContext(ctx, &u, "SELECT id, name FROM t_user WHERE id = ?", 2)
+		so(err, isNil)
+		so(u.Name, eq, "Bob")
+
+		so(len(db.Statements()), eq, 3)
+		db.AssertExecuted(t, "SELECT id, name\n FROM t_user WHERE id = ?", 2)
+
+		db.ResetStatements()
+		so(len(db.Statements()), eq, 0)
+	})
+
+	cv("只展开 IN (?) 中的切片参数", t, func() {
+		db := newDB(t)
+		ctx := context.Background()
+
+		_, err := db.Raw().Exec("INSERT INTO t_user (id, name) VALUES (1, 'a'), (2, 'b'), (3, 'c')")
+		so(err, isNil)
+
+		ext := json.RawMessage(`{"a":1}`)
+		res, err := db.ExecContext(ctx, "UPDATE t_user SET name = ? WHERE id IN (?)", ext, []int64{1, 2})
+		so(err, isNil)
+		n, _ := res.RowsAffected()
+		so(n, eq, 2)
+
+		var names []string
+		err = db.SelectContext(ctx, &names, "SELECT name FROM t_user ORDER BY id")
+		so(err, isNil)
+		so(names, resem, []string{`{"a":1}`, `{"a":1}`, "c"})
+
+		_, err = db.ExecContext(ctx, "DELETE FROM t_user WHERE id IN (?)", []int64{})
+		so(err, notNil)
+	})
+
+	cv("事务与 SAVEPOINT", t, func() {
+		db := newDB(t)
+		ctx := context.Background()
+
+		err := db.TransactionContext(ctx, func(ctx context.Context, _ *sqlx.Tx) error {
+			if _, err := db.ExecContext(ctx, "INSERT INTO t_user (id, name) VALUES (?, ?)", 1, "Alice"); err != nil {
+				return err
+			}
+			// 嵌套的事务失败只回滚到 SAVEPOINT
+			err := db.TransactionContext(ctx, func(ctx context.Context, _ *sqlx.Tx) error {
+				_, err := db.ExecContext(ctx, "INSERT INTO t_user (id, name) VALUES (?, ?)", 2, "Bob")
+				so(err, isNil)
+				return errors.New("inner error")
+			})
+			so(err, notNil)
+			return nil
+		})
+		so(err, isNil)
+
+		var cnt int
+		err = db.GetContext(ctx, &cnt, "SELECT COUNT(*) FROM t_user")
+		so(err, isNil)
+		so(cnt, eq, 1)
+	})
+
+	cv("记录语句时嵌套事务", t, func() {
+		db := newDB(t)
+		ctx := context.Background()
+		so(amcsqlx.InTransaction(ctx, db), eq, false)
+		_, err := db.Raw().ExecContext(ctx, "CREATE TABLE t_other (id INTEGER PRIMARY KEY)")
+		so(err, isNil)
+
+		err = db.TransactionContext(ctx, func(ctx context.Context, _ *sqlx.Tx) error {
+			so(amcsqlx.InTransaction(ctx, db), eq, true)
+			if _, err := db.ExecContext(ctx, "INSERT INTO t_user (id, name) VALUES (?, ?)", 1, "Alice"); err != nil {
+				return err
+			}
+			err := db.TransactionContext(ctx, func(ctx context.Context, _ *sqlx.Tx) error {
+				so(amcsqlx.InTransaction(ctx, db), eq, true)
+				_, err := db.ExecContext(ctx, "INSERT INTO t_user (id, name) VALUES (?, ?)", 2, "Bob")
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			// 不使用事务的 ctx 读取其他表时不需要等待事务占用的连接
+			var cnt int
+			return db.GetContext(context.Background(), &cnt, "SELECT COUNT(*) FROM t_other")
+		})
+		so(err, isNil)
+
+		var names []string
+		so(db.SelectContext(ctx, &names, "SELECT name FROM t_user ORDER BY id"), isNil)
+		so(names, resem, []string{"Alice", "Bob"})
+		db.AssertExecuted(t, "INSERT INTO t_user (id, name) VALUES (?, ?)", 2, "Bob")
+	})
+
+	cv("连接池中的连接访问同一个数据库", t, func() {
+		db := newDB(t)
+		ctx := context.Background()
+		_, err := db.Raw().ExecContext(ctx, "INSERT INTO t_user (id, name) VALUES (?, ?)", 1, "Alice")
+		so(err, isNil)
+
+		conns := make([]*sql.Conn, 0, 3)
+		for i := 0; i < 3; i++ {
+			c, err := db.Raw().Conn(ctx)
+			so(err, isNil)
+			conns = append(conns, c)
+		}
+		for _, c := range conns {
+			var cnt int
+			so(c.QueryRowContext(ctx, "SELECT COUNT(*) FROM t_user").Scan(&cnt), isNil)
+			so(cnt, eq, 1)
+			_ = c.Close()
+		}
+
+		// 不同的 DB 互相隔离
+		var cnt int
+		so(newDB(t).GetContext(ctx, &cnt, "SELECT COUNT(*) FROM t_user"), isNil)
+		so(cnt, eq, 0)
+	})
+
+	cv("panic 时回滚事务", t, func() {
+		db := newDB(t)
+		ctx := context.Background()
+
+		err := db.TransactionContext(ctx, func(ctx context.Context, _ *sqlx.Tx) error {
+			_, _ = db.ExecContext(ctx, "INSERT INTO t_user (id, name) VALUES (?, ?)", 1, "Alice")
+			panic("oops")
+		})
+		so(err, notNil)
+
+		var cnt int
+		err = db.GetContext(ctx, &cnt, "SELECT COUNT(*) FROM t_user")
+		so(err, isNil)
+		so(cnt, eq, 0)
+	})
+}
+
+var _ amcsqlx.Client = (*sqlxtest.DB)(nil)
+
+func TestRWClient(t *testing.T) {
+	cv("读写分离", t, func() {
+		primary, r1, r2 := newDB(t), newDB(t), newDB(t)
+		rw := amcsqlx.NewRWClient(primary, r1, r2)
+		ctx := context.Background()
+
+		// 读操作轮流发往从库
+		var cnt int
+		for i := 0; i < 4; i++ {
+			so(rw.GetContext(ctx, &cnt, "SELECT COUNT(*) FROM t_user"), isNil)
+		}
+		so(len(r1.Statements()), eq, 2)
+		so(len(r2.Statements()), eq, 2)
+		so(len(primary.Statements()), eq, 0)
+
+		// 写操作发往主库
+		_, err := rw.ExecContext(ctx, "INSERT INTO t_user (id, name) VALUES (?, ?)", 1, "Alice")
+		so(err, isNil)
+		so(len(primary.Statements()), eq, 1)
+
+		// WithPrimary 时读操作发往主库
+		so(rw.GetContext(amcsqlx.WithPrimary(ctx), &cnt, "SELECT COUNT(*) FROM t_user"), isNil)
+		so(cnt, eq, 1)
+		so(len(primary.Statements()), eq, 2)
+	})
+
+	cv("事务中的读操作发往主库", t, func() {
+		primary, replica := newDB(t), newDB(t)
+		rw := amcsqlx.NewRWClient(primary, replica)
+		ctx := context.Background()
+
+		err := rw.TransactionContext(ctx, func(ctx context.Context, _ *sqlx.Tx) error {
+			if _, err := rw.ExecContext(ctx, "INSERT INTO t_user (id, name) VALUES (?, ?)", 1, "Alice"); err != nil {
+				return err
+			}
+			var names []string
+			if err := rw.SelectContext(ctx, &names, "SELECT name FROM t_user"); err != nil {
+				return err
+			}
+			so(names, resem, []string{"Alice"})
+			return nil
+		})
+		so(err, isNil)
+		so(len(replica.Statements()), eq, 0)
+		so(len(primary.Statements()), eq, 2)
+
+		// 事务结束之后恢复读写分离
+		var cnt int
+		so(rw.GetContext(ctx, &cnt, "SELECT COUNT(*) FROM t_user"), isNil)
+		so(len(replica.Statements()), eq, 1)
+	})
+
+	cv("主库自身的事务中的读操作也发往主库", t, func() {
+		primary, replica := newDB(t), newDB(t)
+		rw := amcsqlx.NewRWClient(primary, replica)
+		ctx := context.Background()
+
+		err := primary.TransactionContext(ctx, func(ctx context.Context, _ *sqlx.Tx) error {
+			return primary.TransactionContext(ctx, func(ctx context.Context, _ *sqlx.Tx) error {
+				var cnt int
+				return rw.GetContext(ctx, &cnt, "SELECT COUNT(*) FROM t_user")
+			})
+		})
+		so(err, isNil)
+		so(len(replica.Statements()), eq, 0)
+		so(len(primary.Statements()), eq, 1)
+	})
+
+	cv("每个 Client 独立轮询", t, func() {
+		a1, a2 := newDB(t), newDB(t)
+		b1, b2 := newDB(t), newDB(t)
+		rwA := amcsqlx.NewRWClient(newDB(t), a1, a2)
+		rwB := amcsqlx.NewRWClient(newDB(t), b1, b2)
+		ctx := context.Background()
+
+		var cnt int
+		for i := 0; i < 2; i++ {
+			so(rwA.GetContext(ctx, &cnt, "SELECT COUNT(*) FROM t_user"), isNil)
+			so(rwB.GetContext(ctx, &cnt, "SELECT COUNT(*) FROM t_user"), isNil)
+		}
+		so(len(a1.Statements()), eq, 1)
+		so(len(a2.Statements()), eq, 1)
+		so(len(b1.Statements()), eq, 1)
+		so(len(b2.Statements()), eq, 1)
+	})
+
+	cv("ClientGetterRW 返回同一个 Client", t, func() {
+		getter := amcsqlx.ClientGetterRW("trpc.mysql.test.writer", []string{"trpc.mysql.test.reader"})
+		c1, err := getter(context.Background())
+		so(err, isNil)
+		c2, err := getter(context.Background())
+		so(err, isNil)
+		so(c1 == c2, eq, true)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/Andrew-M-C/trpc-go-utils/client/buffer"
 	configutil "github.com/Andrew-M-C/trpc-go-utils/config"
 	"gopkg.in/yaml.v3"
 	"trpc.group/trpc-go/trpc-go"
@@ -53,6 +54,7 @@ func updateClientYAML(client trpc.ClientConfig) {
 	}
 
 	trpc.SetGlobalConfig(trpcGlobal)
+	buffer.NotifyClientConfigUpdated()
 	count("clientUpdate.succ")
 }
 
@@ -5,6 +5,7 @@ go 1.23.0
 toolchain go1.23.5
 
 require (
+	github.com/Andrew-M-C/trpc-go-utils/client/buffer v0.0.0-20261018062856-8345aaf441fa
 	github.com/Andrew-M-C/trpc-go-utils/config v0.0.0-20250116071933-d268d3e7e3ab
 	gopkg.in/yaml.v3 v3.0.1
 	trpc.group/trpc-go/trpc-config-etcd v1.0.0
@@ -15,6 +16,7 @@ require (
 	github.com/Andrew-M-C/go.util/channel v0.0.0-20250116061329-8e3db2afac06 // indirect
 	github.com/Andrew-M-C/go.util/sync v0.0.0-20250116061329-8e3db2afac06 // indirect
 	github.com/Andrew-M-C/go.util/unsafe v0.0.0-20250116061329-8e3db2afac06 // indirect
+	github.com/Andrew-M-C/trpc-go-utils/metrics v0.0.0-20250116064400-067a5f44757f // indirect
 	github.com/BurntSushi/toml v1.5.0 // indirect
 	github.com/andybalholm/brotli v1.1.1 // indirect
 	github.com/coreos/go-semver v0.3.0 // indirect